@@ -0,0 +1,119 @@
+package dbman
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sort"
+	"sync"
+)
+
+// Backend describes how to connect to, and inspect, a kind of database.
+// Backends are registered by name with RegisterBackend, and selected by a
+// Connection's Driver.
+type Backend struct {
+	// Connector creates a driver.Connector for the database described by conn.
+	// conn.Password has already been resolved, i.e. conn.Password.Value is set (unless NoPassword is set).
+	// If conn.ReadOnly is true, sessions should be read only, if the database supports it.
+	Connector func(conn *Connection) (driver.Connector, error)
+
+	// Meta wraps an opened database with the backend's MetaQuerier implementation.
+	Meta func(db Querier) MetaQuerier
+
+	// Validate checks conn for backend specific requirements. (optional)
+	// If nil, host, port, database, and username are required.
+	Validate func(prefix string, conn *Connection) error
+
+	// Configure is called after the database is opened, and after the default
+	// connection pool settings have been applied. (optional)
+	Configure func(db *sql.DB, conn *Connection)
+
+	// Notices wraps connector so that messages sent by the server (e.g. postgres'
+	// RAISE NOTICE) are passed to handler. (optional)
+	Notices func(connector driver.Connector, handler func(msg string)) driver.Connector
+
+	// Placeholder returns the driver's placeholder for the nth (from 1) bind
+	// parameter of a statement. (optional)
+	// If nil, "?" is used.
+	Placeholder func(n int) string
+
+	// Dialect describes how to quote identifiers and literals when generating
+	// SQL (e.g. INSERTs from a query's results). (optional)
+	Dialect Dialect
+
+	// DSNPassword returns the password given in conn's driver specific settings
+	// (e.g. its dsn), if any, which is used before PasswordEnv. (optional)
+	DSNPassword func(conn *Connection) (password string, ok bool, err error)
+
+	// PasswordEnv is an environment variable to check for a password, before
+	// prompting for one. (optional)
+	PasswordEnv string
+
+	// LookupPassword looks for a password for conn (e.g. in a password file),
+	// after PasswordEnv, and before prompting for one. (optional)
+	// conn's host and port are the database's, even if it's connected to through a tunnel.
+	LookupPassword func(conn *Connection) (password string, ok bool, err error)
+
+	// NoPassword disables password resolution entirely.
+	NoPassword bool
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// RegisterBackend makes a backend available to connections whose driver is name.
+// Like sql.Register, it panics if called twice with the same name, or if the
+// backend is missing a Connector or Meta.
+func RegisterBackend(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if backend.Connector == nil || backend.Meta == nil {
+		panic("dbman: RegisterBackend " + name + " is missing a Connector or Meta")
+	}
+	if _, dup := backends[name]; dup {
+		panic("dbman: RegisterBackend called twice for backend " + name)
+	}
+	backends[name] = backend
+}
+
+// Backends returns a sorted list of the names of the registered backends.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupBackend(name string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	backend, ok := backends[name]
+	return backend, ok
+}
+
+// DSNConnector adapts a driver that doesn't provide its own driver.Connector.
+func DSNConnector(d driver.Driver, dsn string) driver.Connector {
+	return dsnConnector{driver: d, dsn: dsn}
+}
+
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
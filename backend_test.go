@@ -0,0 +1,69 @@
+package dbman
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func Test_RegisterBackend(t *testing.T) {
+	validateErr := errors.New("needs a cluster")
+	registerTestBackend(t, "test-backend", Backend{
+		Connector: func(*Connection) (driver.Connector, error) { return nil, errors.New("not implemented") },
+		Meta:      func(db Querier) MetaQuerier { return dbMeta{db} },
+		Validate: func(prefix string, conn *Connection) error {
+			if conn.DriverOpts["cluster"] == "" {
+				return validateErr
+			}
+			return nil
+		},
+	})
+
+	if !stringsContains(Backends(), "test-backend") {
+		t.Error("expected registered backend to be listed:", Backends())
+	}
+
+	t.Run("duplicate panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected registering a duplicate backend to panic")
+			}
+		}()
+		RegisterBackend("test-backend", Backend{
+			Connector: func(*Connection) (driver.Connector, error) { return nil, nil },
+			Meta:      func(db Querier) MetaQuerier { return dbMeta{db} },
+		})
+	})
+
+	t.Run("validation uses backend", func(t *testing.T) {
+		conn := Connection{Driver: "test-backend"}
+		err := conn.validate("conn")
+		var errs errorList
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != validateErr {
+			t.Errorf("expected only the backend's validation error, but got: %v", err)
+		}
+
+		conn.DriverOpts = map[string]string{"cluster": "east"}
+		if err := conn.validate("conn"); err != nil {
+			t.Error("expected connection to be valid, but got:", err)
+		}
+	})
+
+	t.Run("unknown driver", func(t *testing.T) {
+		conn := Connection{Driver: "nope"}
+		if err := conn.validate("conn"); err == nil {
+			t.Error("expected an unregistered driver to be invalid")
+		}
+	})
+}
+
+// registerTestBackend registers backend, and unregisters it once the test is done,
+// so that tests can be run more than once (e.g. with -count).
+func registerTestBackend(t *testing.T, name string, backend Backend) {
+	RegisterBackend(name, backend)
+	t.Cleanup(func() {
+		backendsMu.Lock()
+		defer backendsMu.Unlock()
+		delete(backends, name)
+	})
+}
@@ -0,0 +1,127 @@
+package dbman
+
+import (
+	"strconv"
+	"strings"
+)
+
+// VarLookup returns the value of a bind variable, by name, e.g. "id" for :id, or "1" for $1.
+type VarLookup func(name string) (string, error)
+
+// questionPlaceholder is the default Backend.Placeholder.
+func questionPlaceholder(int) string {
+	return "?"
+}
+
+func dollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// bindVars replaces the :name and $1 style bind variables in stmt with the driver's
+// placeholders, returning the values to pass for them, in order.
+// Variables within quotes and comments (as lexed by the dialect, e.g. mysql's #
+// comments, and backslash escapes) are left alone, as are casts (e.g. x::int),
+// and array slices (e.g. arr[lo:hi]).
+// If lookup is nil, stmt is returned as is.
+func (d Dialect) bindVars(stmt string, lookup VarLookup, placeholder func(n int) string) (string, []interface{}, error) {
+	if lookup == nil {
+		return stmt, nil, nil
+	}
+
+	var (
+		sb   strings.Builder
+		args []interface{}
+		last int // stmt[last:] hasn't been written to sb yet
+	)
+
+	for i := 0; i < len(stmt); {
+		c := stmt[i]
+
+		var name string
+		end := i + 1
+		switch {
+		case c == '-' && strings.HasPrefix(stmt[i:], "--"):
+			i = skipLineComment(stmt, i)
+			continue
+
+		case c == '#' && d.HashComments:
+			i = skipLineComment(stmt, i)
+			continue
+
+		case c == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			i = skipBlockComment(stmt, i)
+			continue
+
+		case c == '\'':
+			i = skipQuoted(stmt, i, c, d.BackslashEscapes || isEscapeString(stmt, i))
+			continue
+
+		case c == '"':
+			i = skipQuoted(stmt, i, c, d.BackslashEscapes)
+			continue
+
+		case c == '`':
+			i = skipQuoted(stmt, i, c, false)
+			continue
+
+		case c == '$':
+			if tag := dollarTag(stmt, i); tag != "" {
+				if tagEnd := strings.Index(stmt[i+len(tag):], tag); tagEnd >= 0 {
+					i += len(tag) + tagEnd + len(tag)
+				} else {
+					i = len(stmt)
+				}
+				continue
+			}
+
+			for end < len(stmt) && stmt[end] >= '0' && stmt[end] <= '9' {
+				end++
+			}
+			if i == 0 || !isIdentByte(stmt[i-1]) {
+				name = stmt[i+1 : end]
+			}
+
+		case c == ':':
+			if end < len(stmt) && stmt[end] == ':' {
+				// a cast
+				i += 2
+				continue
+			}
+
+			for end < len(stmt) && isIdentByte(stmt[end]) && stmt[end] != '$' {
+				end++
+			}
+			if i > 0 && (stmt[i-1] == '[' || isIdentByte(stmt[i-1])) {
+				// an array slice, e.g. arr[lo:hi], or arr[:hi]
+				i = end
+				continue
+			}
+			if end > i+1 && (stmt[i+1] < '0' || stmt[i+1] > '9') {
+				name = stmt[i+1 : end]
+			}
+		}
+
+		if name == "" {
+			i++
+			continue
+		}
+
+		value, err := lookup(name)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, value)
+
+		sb.WriteString(stmt[last:i])
+		sb.WriteString(placeholder(len(args)))
+		i = end
+		last = end
+	}
+
+	if len(args) == 0 {
+		return stmt, nil, nil
+	}
+
+	sb.WriteString(stmt[last:])
+	return sb.String(), args, nil
+}
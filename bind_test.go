@@ -0,0 +1,97 @@
+package dbman
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_bindVars(t *testing.T) {
+	vars := map[string]string{"id": "42", "name": "bob", "1": "first"}
+	lookup := func(name string) (string, error) {
+		if value, ok := vars[name]; ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("no value for %s", name)
+	}
+
+	tests := []struct {
+		name        string
+		dialect     Dialect
+		in          string
+		placeholder func(int) string
+		want        string
+		wantArgs    []interface{}
+		wantErr     bool
+	}{
+		{name: "no variables", in: "SELECT 1", placeholder: questionPlaceholder, want: "SELECT 1"},
+		{
+			name:        "named",
+			in:          "SELECT * FROM users WHERE id = :id AND name = :name",
+			placeholder: questionPlaceholder,
+			want:        "SELECT * FROM users WHERE id = ? AND name = ?",
+			wantArgs:    []interface{}{"42", "bob"},
+		},
+		{
+			name:        "repeated",
+			in:          "SELECT :id, :id",
+			placeholder: dollarPlaceholder,
+			want:        "SELECT $1, $2",
+			wantArgs:    []interface{}{"42", "42"},
+		},
+		{
+			name:        "positional",
+			in:          "SELECT * FROM users WHERE name = $1",
+			placeholder: questionPlaceholder,
+			want:        "SELECT * FROM users WHERE name = ?",
+			wantArgs:    []interface{}{"first"},
+		},
+		{
+			name:        "ignored",
+			in:          "SELECT ':id', \":id\", '2020-01-01 10:00'::timestamp, $$ :id $$ -- :id\n/* :id */",
+			placeholder: dollarPlaceholder,
+			want:        "SELECT ':id', \":id\", '2020-01-01 10:00'::timestamp, $$ :id $$ -- :id\n/* :id */",
+		},
+		{
+			name:        "array slices",
+			in:          "SELECT arr[lo:hi], arr[:hi], arr[1:n] FROM t WHERE id = :id",
+			placeholder: dollarPlaceholder,
+			want:        "SELECT arr[lo:hi], arr[:hi], arr[1:n] FROM t WHERE id = $1",
+			wantArgs:    []interface{}{"42"},
+		},
+		{
+			name:        "mysql escapes",
+			dialect:     Dialect{BackslashEscapes: true, HashComments: true},
+			in:          `SELECT 'it\'s :name', "a\":name" FROM t WHERE id = :id`,
+			placeholder: questionPlaceholder,
+			want:        `SELECT 'it\'s :name', "a\":name" FROM t WHERE id = ?`,
+			wantArgs:    []interface{}{"42"},
+		},
+		{
+			name:        "mysql hash comment",
+			dialect:     Dialect{BackslashEscapes: true, HashComments: true},
+			in:          "SELECT :id # :nope, $2\n",
+			placeholder: questionPlaceholder,
+			want:        "SELECT ? # :nope, $2\n",
+			wantArgs:    []interface{}{"42"},
+		},
+		{name: "assignment", in: "SELECT @x := 1", placeholder: questionPlaceholder, want: "SELECT @x := 1"},
+		{name: "missing", in: "SELECT :nope", placeholder: questionPlaceholder, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := tt.dialect.bindVars(tt.in, lookup, tt.placeholder)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, but got: %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, but got %q", tt.want, got)
+			}
+			if diff := cmp.Diff(tt.wantArgs, args); diff != "" {
+				t.Errorf("unexpected args (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -10,12 +10,19 @@ endfunction
 call remote#host#Register('dbman-nvim', 'x', function('s:Require_dbman'))
 
 call remote#host#RegisterPlugin('dbman-nvim', '0', [
+\ {'type': 'command', 'name': 'DBBegin', 'sync': 1, 'opts': {'bar': '', 'nargs': '0'}},
+\ {'type': 'command', 'name': 'DBCancel', 'sync': 1, 'opts': {'bar': '', 'nargs': '0'}},
+\ {'type': 'command', 'name': 'DBCommit', 'sync': 1, 'opts': {'bar': '', 'nargs': '0'}},
 \ {'type': 'command', 'name': 'DBConnect', 'sync': 1, 'opts': {'complete': 'custom,DBConnectionsF', 'nargs': '1'}},
 \ {'type': 'command', 'name': 'DBConnections', 'sync': 1, 'opts': {'nargs': '0'}},
 \ {'type': 'command', 'name': 'DBDescribe', 'sync': 1, 'opts': {'nargs': '1'}},
+\ {'type': 'command', 'name': 'DBExport', 'sync': 1, 'opts': {'addr': 'lines', 'bar': '', 'complete': 'file', 'nargs': '+', 'range': '%'}},
 \ {'type': 'command', 'name': 'DBRefresh', 'sync': 1, 'opts': {'nargs': '0'}},
-\ {'type': 'command', 'name': 'DBRun', 'sync': 1, 'opts': {'addr': 'lines', 'bar': '', 'nargs': '?', 'range': '%'}},
+\ {'type': 'command', 'name': 'DBRollback', 'sync': 1, 'opts': {'bar': '', 'nargs': '0'}},
+\ {'type': 'command', 'name': 'DBRun', 'sync': 1, 'opts': {'addr': 'lines', 'bang': '', 'bar': '', 'nargs': '?', 'range': '%'}},
+\ {'type': 'command', 'name': 'DBRunAsInserts', 'sync': 1, 'opts': {'addr': 'lines', 'bar': '', 'nargs': '1', 'range': '%'}},
 \ {'type': 'command', 'name': 'DBSchemas', 'sync': 1, 'opts': {'nargs': '0'}},
 \ {'type': 'command', 'name': 'DBTables', 'sync': 1, 'opts': {'nargs': '*'}},
 \ {'type': 'function', 'name': 'DBConnectionsF', 'sync': 1, 'opts': {}},
+\ {'type': 'function', 'name': 'DBStatus', 'sync': 1, 'opts': {}},
 \ ])
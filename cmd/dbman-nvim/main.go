@@ -2,11 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"regexp"
 	"strings"
 	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 
 	"dabbertorres.dev/dbman"
 	"github.com/neovim/go-client/nvim"
@@ -23,6 +28,7 @@ func main() {
 	defer db.Close()
 	state := pluginState{
 		db:           db,
+		valueDisplay: cfg.Display,
 		displayBuf:   -1,
 		displayWin:   -1,
 		displayCache: make(map[string][]schemaState),
@@ -31,6 +37,7 @@ func main() {
 	plugin.Main(func(p *plugin.Plugin) error {
 		p.HandleFunction(listConnectionsFunc(&state))
 		p.HandleFunction(listTablesFunc(&state))
+		p.HandleFunction(statusFunc(&state))
 
 		p.HandleCommand(listConnections(&state))
 		p.HandleCommand(listSchemas(&state))
@@ -39,6 +46,12 @@ func main() {
 		p.HandleCommand(switchConnection(&state))
 		p.HandleCommand(refreshSchema(&state))
 		p.HandleCommand(runQuery(&state))
+		p.HandleCommand(exportQuery(&state))
+		p.HandleCommand(runAsInserts(&state))
+		p.HandleCommand(cancelQuery(&state))
+		p.HandleCommand(transaction(&state, "DBBegin", state.db.Begin))
+		p.HandleCommand(transaction(&state, "DBCommit", state.db.Commit))
+		p.HandleCommand(transaction(&state, "DBRollback", state.db.Rollback))
 		return nil
 	})
 }
@@ -58,6 +71,9 @@ func listTablesFunc(state *pluginState) (*plugin.FunctionOptions, func(*nvim.Nvi
 		Name: "DBTables",
 	}
 	return opts, func(*nvim.Nvim, []interface{}) (string, error) {
+		state.windowMu.Lock()
+		defer state.windowMu.Unlock()
+
 		cache, ok := state.displayCache[state.db.CurrentName()]
 		if !ok {
 			if err := state.refreshCache(); err != nil {
@@ -104,7 +120,7 @@ func listSchemas(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim) e
 	}
 
 	return opts, func(api *nvim.Nvim) error {
-		schemas, _ := state.db.ListSchemas()
+		schemas, _ := state.db.ListSchemas(context.Background())
 		return api.WriteOut(strings.Join(schemas, "\n") + "\n")
 	}
 }
@@ -121,21 +137,21 @@ func listTables(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim, []
 		switch len(args) {
 		case 0:
 			var err error
-			tables, err = state.db.ListTables("")
+			tables, err = state.db.ListTables(context.Background(), "")
 			if err != nil {
 				return err
 			}
 
 		case 1:
 			var err error
-			tables, err = state.db.ListTables(args[0])
+			tables, err = state.db.ListTables(context.Background(), args[0])
 			if err != nil {
 				return err
 			}
 
 		default:
 			for _, schema := range args {
-				schemaTables, err := state.db.ListTables(schema)
+				schemaTables, err := state.db.ListTables(context.Background(), schema)
 				if err != nil {
 					return err
 				}
@@ -160,7 +176,7 @@ func describeTable(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim,
 	}
 	return opts, func(api *nvim.Nvim, args []string) error {
 		table := strings.TrimSpace(args[0])
-		schema, err := state.db.DescribeTable(table)
+		schema, err := state.db.DescribeTable(context.Background(), table)
 		if err != nil {
 			return err
 		}
@@ -175,6 +191,20 @@ func describeTable(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim,
 	}
 }
 
+// statusFunc describes the active connection, for use in a statusline.
+func statusFunc(state *pluginState) (*plugin.FunctionOptions, func(*nvim.Nvim, []interface{}) (string, error)) {
+	opts := &plugin.FunctionOptions{
+		Name: "DBStatus",
+	}
+	return opts, func(*nvim.Nvim, []interface{}) (string, error) {
+		status := state.db.CurrentName()
+		if state.db.InTransaction() {
+			status += " [tx]"
+		}
+		return status, nil
+	}
+}
+
 func switchConnection(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim, []string) error) {
 	opts := &plugin.CommandOptions{
 		Name:     "DBConnect",
@@ -182,6 +212,10 @@ func switchConnection(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nv
 		Complete: "custom,DBConnections",
 	}
 	return opts, func(api *nvim.Nvim, args []string) error {
+		if state.db.InTransaction() {
+			api.WriteOut(fmt.Sprintf("warning: '%s' has an open transaction, which stays open until committed or rolled back\n", state.db.CurrentName()))
+		}
+
 		go func() {
 			if err := state.db.SwitchConnection(strings.TrimSpace(args[0]), passwordPrompt(api)); err != nil {
 				api.WritelnErr(fmt.Sprintf("failed to connect to '%s': %v", args[0], err))
@@ -217,101 +251,561 @@ func refreshSchema(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim)
 	}
 }
 
-func runQuery(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim, []string, [2]int) error) {
+func cancelQuery(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim) error) {
+	opts := &plugin.CommandOptions{
+		Name:  "DBCancel",
+		NArgs: "0",
+		Bar:   true,
+	}
+	return opts, func(api *nvim.Nvim) error {
+		if !state.cancelQuery() {
+			return errors.New("no query is running")
+		}
+		return nil
+	}
+}
+
+// transaction runs action, e.g. to begin or commit a transaction.
+func transaction(state *pluginState, name string, action func(context.Context) error) (*plugin.CommandOptions, func(*nvim.Nvim) error) {
+	opts := &plugin.CommandOptions{
+		Name:  name,
+		NArgs: "0",
+		Bar:   true,
+	}
+	return opts, func(api *nvim.Nvim) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		if !state.startQuery(cancel) {
+			cancel()
+			return errors.New("a query is running, use :DBCancel to stop it")
+		}
+		defer state.finishQuery()
+
+		if err := action(ctx); err != nil {
+			return err
+		}
+		return api.Command("redrawstatus")
+	}
+}
+
+func runQuery(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim, []string, [2]int, bool) error) {
 	opts := &plugin.CommandOptions{
 		Name:  "DBRun",
 		NArgs: "?",
 		Range: "%",
 		Addr:  "lines",
 		Bar:   true,
+		Bang:  true,
 	}
-	return opts, func(api *nvim.Nvim, _ []string, bufRange [2]int) error {
-		// grab the query
-		queryBuffer, err := api.CurrentBuffer()
+	return opts, func(api *nvim.Nvim, _ []string, bufRange [2]int, bang bool) error {
+		query, vars, err := bufferQuery(api, bufRange)
 		if err != nil {
 			return err
 		}
 
-		queryLines, err := api.BufferLines(queryBuffer, bufRange[0]-1, bufRange[1], false)
+		display, err := displaySettings(api, state)
 		if err != nil {
 			return err
 		}
 
-		query := string(bytes.Join(queryLines, []byte{' '}))
+		expanded := expandedMode(api, bang)
+
+		return inBackground(api, state, func(ctx context.Context) error {
+			if err := executeQuery(ctx, api, state, query, vars, &display, expanded); err != nil {
+				return err
+			}
+
+			autoDisplay := true
+			_ = api.Var("db_auto_display_schema", &autoDisplay)
+			if autoDisplay {
+				// _very_ simple attempt at detecting if the schema display needs refreshing
+				if matched, _ := regexp.MatchString(`\stable\s`, strings.ToLower(query)); matched {
+					go func() {
+						if err := state.displaySchemas(api, true); err != nil {
+							api.WritelnErr("failed to update schema display: " + err.Error())
+						}
+					}()
+				}
+			}
+			return nil
+		})
+	}
+}
+
+func exportQuery(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim, []string, [2]int) error) {
+	opts := &plugin.CommandOptions{
+		Name:     "DBExport",
+		NArgs:    "+",
+		Range:    "%",
+		Addr:     "lines",
+		Bar:      true,
+		Complete: "file",
+	}
+	return opts, func(api *nvim.Nvim, args []string, bufRange [2]int) error {
+		if len(args) != 2 {
+			return errors.New("a format and a file name must be specified")
+		}
+		format := args[0]
+		if _, ok := dbman.LookupFormat(format); !ok {
+			return fmt.Errorf("unknown format, must be one of: %s", strings.Join(dbman.Formats(), ", "))
+		}
+
+		var path string
+		if err := api.Call("expand", &path, args[1]); err != nil {
+			return err
+		}
 
-		// run it!
-		result, err := state.db.Query(query)
+		query, vars, err := bufferQuery(api, bufRange)
 		if err != nil {
 			return err
 		}
 
-		if result == nil || len(result.Columns) == 0 {
-			api.WriteOut("no results\n")
-			return nil
+		display, err := displaySettings(api, state)
+		if err != nil {
+			return err
 		}
 
-		// format it!
-		marks := make([]string, len(result.Columns))
-		for i := range marks {
-			marks[i] = "%v"
+		return inBackground(api, state, func(ctx context.Context) error {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			var count int
+			err = state.db.QueryScript(ctx, query, vars, func(result *dbman.StatementResult, rows *dbman.Rows) error {
+				if rows == nil {
+					return nil
+				}
+
+				rw, err := dbman.NewResultWriter(f, format, rows.Columns, rows.ColumnTypes, &display)
+				if err != nil {
+					return err
+				}
+				for batch := rows.Batch(streamBatchSize); len(batch) != 0; batch = rows.Batch(streamBatchSize) {
+					if err := rw.Write(batch); err != nil {
+						return err
+					}
+				}
+				if err := rw.Close(); err != nil {
+					return err
+				}
+				count += rw.Count()
+
+				if rows.More() {
+					api.WritelnErr(fmt.Sprintf("more rows available, only the first %d were exported", rw.Count()))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := f.Close(); err != nil {
+				return err
+			}
+			return api.WriteOut(fmt.Sprintf("exported %d rows to %s\n", count, path))
+		})
+	}
+}
+
+func runAsInserts(state *pluginState) (*plugin.CommandOptions, func(*nvim.Nvim, []string, [2]int) error) {
+	opts := &plugin.CommandOptions{
+		Name:  "DBRunAsInserts",
+		NArgs: "1",
+		Range: "%",
+		Addr:  "lines",
+		Bar:   true,
+	}
+	return opts, func(api *nvim.Nvim, args []string, bufRange [2]int) error {
+		table := args[0]
+
+		query, vars, err := bufferQuery(api, bufRange)
+		if err != nil {
+			return err
 		}
-		printFmt := strings.Join(marks, " |\t") + "\t\n"
 
-		var sb strings.Builder
-		writer := tabwriter.NewWriter(&sb, 3, 4, 1, ' ', tabwriter.AlignRight)
+		return inBackground(api, state, func(ctx context.Context) error {
+			var (
+				sb      strings.Builder
+				dialect = state.db.Dialect()
+			)
+			err := state.db.QueryScript(ctx, query, vars, func(result *dbman.StatementResult, rows *dbman.Rows) error {
+				if rows == nil {
+					return nil
+				}
+
+				var count int
+				batch := dbman.QueryResult{Columns: rows.Columns, ColumnTypes: rows.ColumnTypes}
+				for batch.Rows = rows.Batch(streamBatchSize); len(batch.Rows) != 0; batch.Rows = rows.Batch(streamBatchSize) {
+					if err := dialect.WriteInserts(&sb, table, &batch); err != nil {
+						return err
+					}
+					count += len(batch.Rows)
+				}
+
+				if rows.More() {
+					api.WritelnErr(fmt.Sprintf("more rows available, only the first %d were converted", count))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
 
-		colNames := make([]interface{}, len(result.Columns))
-		for i, col := range result.Columns {
-			colNames[i] = col
+			if sb.Len() == 0 {
+				return api.WriteOut("no rows\n")
+			}
+
+			lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+			buf := make([][]byte, len(lines))
+			for i, line := range lines {
+				buf[i] = []byte(line)
+			}
+
+			// buffer 0 is the current buffer, i.e. the new one
+			batch := api.NewBatch()
+			batch.Command("new")
+			batch.SetBufferLines(0, 0, -1, false, buf)
+			batch.SetBufferOption(0, "filetype", "sql")
+			return batch.Execute()
+		})
+	}
+}
+
+// bufferQuery returns the SQL in bufRange of the current buffer, and how to resolve its bind variables.
+func bufferQuery(api *nvim.Nvim, bufRange [2]int) (string, dbman.VarLookup, error) {
+	queryBuffer, err := api.CurrentBuffer()
+	if err != nil {
+		return "", nil, err
+	}
+
+	queryLines, err := api.BufferLines(queryBuffer, bufRange[0]-1, bufRange[1], false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// variables may be provided by the buffer, e.g. let b:db_vars = {'id': 42}
+	var bufVars map[string]interface{}
+	_ = api.BufferVar(queryBuffer, "db_vars", &bufVars)
+
+	return string(bytes.Join(queryLines, []byte{'\n'})), varLookup(api, bufVars), nil
+}
+
+// inBackground runs query in the background, so that it can be canceled with :DBCancel.
+// Only one query may run at a time.
+func inBackground(api *nvim.Nvim, state *pluginState, query func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if !state.startQuery(cancel) {
+		cancel()
+		return errors.New("a query is already running, use :DBCancel to stop it")
+	}
+
+	go func() {
+		defer state.finishQuery()
+
+		if err := query(ctx); err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				api.WritelnErr("query canceled")
+			} else {
+				api.WritelnErr("query failed: " + err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// displaySettings returns the configured display settings, overridden by any
+// g:db_<setting> variables, e.g. let g:db_null = '∅'.
+func displaySettings(api *nvim.Nvim, state *pluginState) (dbman.Display, error) {
+	display := state.valueDisplay
+	for _, name := range dbman.DisplaySettings() {
+		var value interface{}
+		if err := api.Var("db_"+name, &value); err != nil {
+			// not set
+			continue
+		}
+		if err := display.Set(name, fmt.Sprint(value)); err != nil {
+			return display, fmt.Errorf("g:db_%s: %w", name, err)
+		}
+	}
+	return display, nil
+}
+
+// expandedMode returns whether results are displayed expanded: "on" if bang
+// (i.e. :DBRun!), otherwise according to g:db_expanded, which may be 1, or 'auto'
+// to expand results wider than the output window.
+func expandedMode(api *nvim.Nvim, bang bool) string {
+	if bang {
+		return "on"
+	}
+
+	var value interface{}
+	if err := api.Var("db_expanded", &value); err != nil {
+		// not set
+		return "off"
+	}
+	switch mode := strings.ToLower(fmt.Sprint(value)); mode {
+	case "1", "on", "true":
+		return "on"
+	case "auto":
+		return mode
+	default:
+		return "off"
+	}
+}
+
+// varLookup resolves bind variables from bufVars, or by prompting for them.
+// Each variable is only prompted for once.
+func varLookup(api *nvim.Nvim, bufVars map[string]interface{}) dbman.VarLookup {
+	prompted := make(map[string]string)
+	return func(name string) (string, error) {
+		if value, ok := bufVars[name]; ok {
+			return fmt.Sprint(value), nil
+		}
+		if value, ok := prompted[name]; ok {
+			return value, nil
+		}
+
+		answers, err := passwordPrompt(api)("", "", []string{name + ": "}, []bool{true})
+		if err != nil {
+			return "", err
+		}
+		prompted[name] = answers[0]
+		return answers[0], nil
+	}
+}
+
+// streamBatchSize is how many rows are formatted and written to the output buffer at a time.
+const streamBatchSize = 100
+
+// executeQuery runs each statement in query, and displays their results in the output window.
+// Rows are shown as they're read, a batch at a time.
+// expanded is one of "on", "off", or "auto", see expandedMode.
+func executeQuery(ctx context.Context, api *nvim.Nvim, state *pluginState, query string, vars dbman.VarLookup, display *dbman.Display, expanded string) error {
+	stmts := state.db.Dialect().SplitStatements(query)
+	if len(stmts) == 0 {
+		api.WriteOut("no statements\n")
+		return nil
+	}
+
+	// the first write replaces the output window's contents, later writes are appended
+	shown := false
+	write := func(lines []string) error {
+		if shown {
+			return state.appendOutput(api, lines)
 		}
-		fmt.Fprintf(writer, printFmt, colNames...)
+		shown = true
+		name := fmt.Sprintf("[%s] %s", state.db.CurrentName(), statementSummary(query))
+		return state.showOutput(api, name, lines)
+	}
 
-		for _, row := range result.Rows {
-			fmt.Fprintf(writer, printFmt, row...)
+	n := 0
+	return state.db.QueryScript(ctx, query, vars, func(result *dbman.StatementResult, rows *dbman.Rows) error {
+		// a single statement without any results is only worth a message
+		if len(stmts) == 1 && rows == nil && result.Err == nil {
+			for _, notice := range result.Notices {
+				api.WriteOut(notice + "\n")
+			}
+			api.WriteOut(resultSummary(result, 0) + "\n")
+			return nil
 		}
 
-		if err := writer.Flush(); err != nil {
+		var lines []string
+		if len(stmts) > 1 {
+			if n != 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, "-- "+statementSummary(result.Statement))
+		}
+		n++
+
+		lines = append(lines, result.Notices...)
+		if result.Err != nil {
+			// returned by QueryScript
+			return write(append(lines, "ERROR: "+result.Err.Error()))
+		}
+		if rows == nil {
+			return write(append(lines, resultSummary(result, 0)))
+		}
+
+		start := time.Now()
+		batch := rows.Batch(streamBatchSize)
+
+		// when auto, rows are expanded if the first batch is wider than the window
+		wide := expanded == "on"
+		if expanded == "auto" {
+			probe, err := newTableWriter(rows, display, false)
+			if err != nil {
+				return err
+			}
+			first, err := probe.format(batch)
+			if err != nil {
+				return err
+			}
+			wide = tableWidth(first) > state.outputWidth(api)
+		}
+
+		table, err := newTableWriter(rows, display, wide)
+		if err != nil {
 			return err
 		}
 
-		if state.outputWin == 0 {
-			state.outputBuf, state.outputWin, err = openSplitWindow(api, false, state.outputBuf)
+		for first := true; first || len(batch) != 0; first = false {
+			formatted, err := table.format(batch)
 			if err != nil {
 				return err
 			}
+			if err := write(append(lines, formatted...)); err != nil {
+				return err
+			}
+			lines = nil
+			batch = rows.Batch(streamBatchSize)
+		}
+		if err := rows.Err(); err != nil {
+			return err
 		}
 
-		lines := strings.Split(sb.String(), "\n")
+		if rows.More() {
+			lines = append(lines, fmt.Sprintf("(more rows available, only the first %d are shown)", table.count))
+		}
+		result.Duration += time.Since(start)
+		return write(append(lines, resultSummary(result, table.count)))
+	})
+}
+
+// tableWriter formats rows for the output window a batch at a time, either
+// aligned under their column names, or expanded, as a block of "column | value"
+// lines per row.
+type tableWriter struct {
+	columns     []string
+	columnTypes []string
+	display     *dbman.Display
+	expanded    *dbman.ResultWriter
+	sb          strings.Builder // written to by expanded
+	count       int             // rows formatted so far
+	widths      []int           // of each column, from its name and the first batch
+}
 
-		// insert a divider
-		lines = append(lines, "")
-		copy(lines[2:], lines[1:])
-		lines[1] = strings.Repeat("-", len(lines[0]))
+func newTableWriter(rows *dbman.Rows, display *dbman.Display, expanded bool) (*tableWriter, error) {
+	t := &tableWriter{
+		columns:     rows.Columns,
+		columnTypes: rows.ColumnTypes,
+		display:     display,
+	}
+	if expanded {
+		var err error
+		if t.expanded, err = dbman.NewResultWriter(&t.sb, "expanded", rows.Columns, rows.ColumnTypes, display); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
 
-		batch := api.NewBatch()
-		batch.SetBufferName(state.outputBuf, fmt.Sprintf("[%s] %s", state.db.CurrentName(), query))
-		batch.SetCurrentWindow(state.outputWin)
-		batch.SetCurrentBuffer(state.outputBuf)
-		batch.Command("%d")
-		batch.Put(lines, "l", false, false)
-		batch.SetWindowCursor(state.outputWin, [2]int{1, 1})
-		if err := batch.Execute(); err != nil {
-			return err
+// format returns the lines for a batch of rows, and the column names with the first.
+// Columns are as wide as their name or widest value in the first batch, and later
+// values that don't fit are truncated, so that every batch lines up.
+func (t *tableWriter) format(rows [][]interface{}) ([]string, error) {
+	defer func() { t.count += len(rows) }()
+
+	if t.expanded != nil {
+		t.sb.Reset()
+		if err := t.expanded.Write(rows); err != nil {
+			return nil, err
+		}
+		if t.sb.Len() == 0 {
+			return nil, nil
 		}
+		return strings.Split(strings.TrimSuffix(t.sb.String(), "\n"), "\n"), nil
+	}
 
-		autoDisplay := true
-		_ = api.Var("db_auto_display_schema", &autoDisplay)
-		if autoDisplay {
-			// _very_ simple attempt at detecting if the schema display needs refreshing
-			if matched, _ := regexp.MatchString(` table `, strings.ToLower(query)); matched {
-				go func() {
-					if err := state.displaySchemas(api, true); err != nil {
-						api.WritelnErr("failed to update schema display: " + err.Error())
-					}
-				}()
+	cells := make([][]string, len(rows))
+	for n, row := range rows {
+		cells[n] = make([]string, len(row))
+		for i, val := range row {
+			var colType string
+			if i < len(t.columnTypes) {
+				colType = t.columnTypes[i]
 			}
+			cells[n][i] = t.display.Value(val, colType)
 		}
+	}
 
-		return nil
+	var lines []string
+	if t.widths == nil {
+		t.widths = make([]int, len(t.columns))
+		for i, col := range t.columns {
+			t.widths[i] = utf8.RuneCountInString(col)
+			for _, row := range cells {
+				if n := utf8.RuneCountInString(row[i]); n > t.widths[i] {
+					t.widths[i] = n
+				}
+			}
+		}
+
+		header := t.line(t.columns)
+		lines = append(lines, header, strings.Repeat("-", utf8.RuneCountInString(header)))
+	}
+
+	for _, row := range cells {
+		lines = append(lines, t.line(row))
+	}
+	return lines, nil
+}
+
+// line right aligns each of cells in its column, truncating those too wide for it.
+func (t *tableWriter) line(cells []string) string {
+	var sb strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			sb.WriteString(" |")
+		}
+		width := t.widths[i]
+		if n := utf8.RuneCountInString(cell); n <= width {
+			sb.WriteString(strings.Repeat(" ", width-n+1) + cell)
+		} else if width > 0 {
+			sb.WriteString(" " + string([]rune(cell)[:width-1]) + "…")
+		} else {
+			sb.WriteString(" ")
+		}
+	}
+	return sb.String()
+}
+
+// tableWidth returns the width of the widest of lines.
+func tableWidth(lines []string) int {
+	var width int
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+// resultSummary describes result, which had count rows if it returned any.
+func resultSummary(result *dbman.StatementResult, count int) string {
+	duration := result.Duration.Round(time.Microsecond)
+
+	switch {
+	case result.Columns != nil:
+		return fmt.Sprintf("(%d rows, %s)", count, duration)
+
+	case result.Command != "":
+		return fmt.Sprintf("%s (%s)", result.Command, duration)
+
+	default:
+		return fmt.Sprintf("OK (%s)", duration)
+	}
+}
+
+// statementSummary collapses stmt onto a single, reasonably short, line.
+func statementSummary(stmt string) string {
+	const maxLen = 80
+
+	summary := []rune(strings.Join(strings.Fields(stmt), " "))
+	if len(summary) > maxLen {
+		return string(summary[:maxLen-3]) + "..."
 	}
+	return string(summary)
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"dabbertorres.dev/dbman"
+	"github.com/google/go-cmp/cmp"
 	"github.com/neovim/go-client/nvim"
 	"github.com/neovim/go-client/nvim/plugin"
 )
@@ -42,7 +43,7 @@ func Test_listConnections(t *testing.T) {
 				Port:              5432,
 				Database:          "postgres",
 				Username:          "postgres",
-				Password:          "postgres",
+				Password:          dbman.Secret{Value: "postgres"},
 				Driver:            "postgres",
 				Tunnel:            "",
 				ConnectTimeoutSec: 30,
@@ -74,3 +75,33 @@ func Test_listConnections(t *testing.T) {
 		t.Errorf("expected output to be 'local', but was: '%s'", output)
 	}
 }
+
+func Test_tableWriter_format(t *testing.T) {
+	table := &tableWriter{
+		columns: []string{"id", "name"},
+		display: &dbman.Display{},
+	}
+
+	var lines []string
+	for _, batch := range [][][]interface{}{
+		{{int64(1), "alice"}},
+		{{int64(22), "bo"}, {int64(3), "christopher"}},
+	} {
+		formatted, err := table.format(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, formatted...)
+	}
+
+	want := []string{
+		" id |  name",
+		"-----------",
+		"  1 | alice",
+		" 22 |    bo",
+		"  3 | chri…",
+	}
+	if diff := cmp.Diff(want, lines); diff != "" {
+		t.Errorf("expected later batches to keep the first's widths (-want +got):\n%s", diff)
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	context "context"
 	dbman "dabbertorres.dev/dbman"
 	gomock "github.com/golang/mock/gomock"
 	ssh "golang.org/x/crypto/ssh"
@@ -78,61 +79,130 @@ func (mr *MockdbManagerMockRecorder) SwitchConnection(connName, prompter interfa
 }
 
 // ListTables mocks base method
-func (m *MockdbManager) ListTables(schema string) ([]string, error) {
+func (m *MockdbManager) ListTables(ctx context.Context, schema string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTables", schema)
+	ret := m.ctrl.Call(m, "ListTables", ctx, schema)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListTables indicates an expected call of ListTables
-func (mr *MockdbManagerMockRecorder) ListTables(schema interface{}) *gomock.Call {
+func (mr *MockdbManagerMockRecorder) ListTables(ctx, schema interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTables", reflect.TypeOf((*MockdbManager)(nil).ListTables), schema)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTables", reflect.TypeOf((*MockdbManager)(nil).ListTables), ctx, schema)
 }
 
 // ListSchemas mocks base method
-func (m *MockdbManager) ListSchemas() ([]string, error) {
+func (m *MockdbManager) ListSchemas(ctx context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListSchemas")
+	ret := m.ctrl.Call(m, "ListSchemas", ctx)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListSchemas indicates an expected call of ListSchemas
-func (mr *MockdbManagerMockRecorder) ListSchemas() *gomock.Call {
+func (mr *MockdbManagerMockRecorder) ListSchemas(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSchemas", reflect.TypeOf((*MockdbManager)(nil).ListSchemas))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSchemas", reflect.TypeOf((*MockdbManager)(nil).ListSchemas), ctx)
 }
 
 // DescribeTable mocks base method
-func (m *MockdbManager) DescribeTable(name string) (*dbman.TableSchema, error) {
+func (m *MockdbManager) DescribeTable(ctx context.Context, name string) (*dbman.TableSchema, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DescribeTable", name)
+	ret := m.ctrl.Call(m, "DescribeTable", ctx, name)
 	ret0, _ := ret[0].(*dbman.TableSchema)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DescribeTable indicates an expected call of DescribeTable
-func (mr *MockdbManagerMockRecorder) DescribeTable(name interface{}) *gomock.Call {
+func (mr *MockdbManagerMockRecorder) DescribeTable(ctx, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeTable", reflect.TypeOf((*MockdbManager)(nil).DescribeTable), name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeTable", reflect.TypeOf((*MockdbManager)(nil).DescribeTable), ctx, name)
 }
 
-// Query mocks base method
-func (m *MockdbManager) Query(script string) (*dbman.QueryResult, error) {
+// QueryScript mocks base method
+func (m *MockdbManager) QueryScript(ctx context.Context, script string, vars dbman.VarLookup, fn dbman.StatementFunc) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Query", script)
-	ret0, _ := ret[0].(*dbman.QueryResult)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "QueryScript", ctx, script, vars, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QueryScript indicates an expected call of QueryScript
+func (mr *MockdbManagerMockRecorder) QueryScript(ctx, script, vars, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryScript", reflect.TypeOf((*MockdbManager)(nil).QueryScript), ctx, script, vars, fn)
+}
+
+// Begin mocks base method
+func (m *MockdbManager) Begin(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Begin indicates an expected call of Begin
+func (mr *MockdbManagerMockRecorder) Begin(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockdbManager)(nil).Begin), ctx)
+}
+
+// Commit mocks base method
+func (m *MockdbManager) Commit(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit
+func (mr *MockdbManagerMockRecorder) Commit(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockdbManager)(nil).Commit), ctx)
+}
+
+// Rollback mocks base method
+func (m *MockdbManager) Rollback(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rollback indicates an expected call of Rollback
+func (mr *MockdbManagerMockRecorder) Rollback(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockdbManager)(nil).Rollback), ctx)
+}
+
+// InTransaction mocks base method
+func (m *MockdbManager) InTransaction() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTransaction")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// InTransaction indicates an expected call of InTransaction
+func (mr *MockdbManagerMockRecorder) InTransaction() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTransaction", reflect.TypeOf((*MockdbManager)(nil).InTransaction))
+}
+
+// Dialect mocks base method
+func (m *MockdbManager) Dialect() dbman.Dialect {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dialect")
+	ret0, _ := ret[0].(dbman.Dialect)
+	return ret0
 }
 
-// Query indicates an expected call of Query
-func (mr *MockdbManagerMockRecorder) Query(script interface{}) *gomock.Call {
+// Dialect indicates an expected call of Dialect
+func (mr *MockdbManagerMockRecorder) Dialect() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockdbManager)(nil).Query), script)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dialect", reflect.TypeOf((*MockdbManager)(nil).Dialect))
 }
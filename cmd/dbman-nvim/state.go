@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
 	"strings"
+	"sync"
 	"text/tabwriter"
 
 	"dabbertorres.dev/dbman"
@@ -15,19 +18,32 @@ type dbManager interface {
 	CurrentName() string
 	ListConnections() (names []string, active []bool)
 	SwitchConnection(connName string, prompter ssh.KeyboardInteractiveChallenge) error
-	ListTables(schema string) ([]string, error)
-	ListSchemas() ([]string, error)
-	DescribeTable(name string) (*dbman.TableSchema, error)
-	Query(script string) (*dbman.QueryResult, error)
+	ListTables(ctx context.Context, schema string) ([]string, error)
+	ListSchemas(ctx context.Context) ([]string, error)
+	DescribeTable(ctx context.Context, name string) (*dbman.TableSchema, error)
+	QueryScript(ctx context.Context, script string, vars dbman.VarLookup, fn dbman.StatementFunc) error
+	Begin(ctx context.Context) error
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+	InTransaction() bool
+	Dialect() dbman.Dialect
 }
 
 type pluginState struct {
 	db           dbManager
+	valueDisplay dbman.Display // how values are shown, unless overridden by g:db_* variables
+
+	// windowMu guards the windows, buffers, and cache below, which are used by
+	// commands that run in the background, e.g. queries, and :DBRefresh.
+	windowMu     sync.Mutex
 	displayCache map[string][]schemaState
 	displayBuf   nvim.Buffer
 	displayWin   nvim.Window
 	outputBuf    nvim.Buffer
 	outputWin    nvim.Window
+
+	queryMu     sync.Mutex
+	queryCancel context.CancelFunc // non-nil while a query is running
 }
 
 type schemaState struct {
@@ -35,7 +51,45 @@ type schemaState struct {
 	Tables []dbman.TableSchema
 }
 
+// startQuery records cancel as the running query's.
+// If a query is already running, false is returned.
+func (s *pluginState) startQuery(cancel context.CancelFunc) bool {
+	s.queryMu.Lock()
+	defer s.queryMu.Unlock()
+
+	if s.queryCancel != nil {
+		return false
+	}
+	s.queryCancel = cancel
+	return true
+}
+
+func (s *pluginState) finishQuery() {
+	s.queryMu.Lock()
+	defer s.queryMu.Unlock()
+
+	if s.queryCancel != nil {
+		s.queryCancel()
+		s.queryCancel = nil
+	}
+}
+
+// cancelQuery cancels the running query, if any.
+func (s *pluginState) cancelQuery() bool {
+	s.queryMu.Lock()
+	defer s.queryMu.Unlock()
+
+	if s.queryCancel == nil {
+		return false
+	}
+	s.queryCancel()
+	return true
+}
+
 func (s *pluginState) displaySchemas(api *nvim.Nvim, refreshCache bool) error {
+	s.windowMu.Lock()
+	defer s.windowMu.Unlock()
+
 	var (
 		validBuf bool
 		validWin bool
@@ -120,8 +174,11 @@ func (s *pluginState) displaySchemas(api *nvim.Nvim, refreshCache bool) error {
 	return batch.Execute()
 }
 
+// refreshCache queries for the current connection's schemas. s.windowMu must be held.
 func (s *pluginState) refreshCache() error {
-	schemaNames, err := s.db.ListSchemas()
+	ctx := context.Background()
+
+	schemaNames, err := s.db.ListSchemas(ctx)
 	if err != nil {
 		return err
 	}
@@ -131,14 +188,14 @@ func (s *pluginState) refreshCache() error {
 	for i, name := range schemaNames {
 		schema := &cache[i]
 		schema.Name = name
-		tables, err := s.db.ListTables(name)
+		tables, err := s.db.ListTables(ctx, name)
 		if err != nil {
 			return err
 		}
 
 		schema.Tables = make([]dbman.TableSchema, len(tables))
 		for i, name := range tables {
-			tableSchema, err := s.db.DescribeTable(schema.Name + "." + name)
+			tableSchema, err := s.db.DescribeTable(ctx, schema.Name+"."+name)
 			if err != nil {
 				return err
 			}
@@ -149,6 +206,64 @@ func (s *pluginState) refreshCache() error {
 	return nil
 }
 
+// showOutput replaces the contents of the output window (opening it, if necessary) with lines.
+func (s *pluginState) showOutput(api *nvim.Nvim, name string, lines []string) error {
+	s.windowMu.Lock()
+	defer s.windowMu.Unlock()
+
+	if s.outputWin == 0 {
+		var err error
+		s.outputBuf, s.outputWin, err = openSplitWindow(api, false, s.outputBuf)
+		if err != nil {
+			return err
+		}
+	}
+
+	buf := make([][]byte, len(lines))
+	for i, line := range lines {
+		buf[i] = []byte(line)
+	}
+
+	batch := api.NewBatch()
+	batch.SetBufferName(s.outputBuf, name)
+	batch.SetBufferLines(s.outputBuf, 0, -1, false, buf)
+	batch.SetCurrentWindow(s.outputWin)
+	batch.SetCurrentBuffer(s.outputBuf)
+	batch.SetWindowCursor(s.outputWin, [2]int{1, 1})
+	return batch.Execute()
+}
+
+// appendOutput appends lines to the output window, opened by showOutput.
+func (s *pluginState) appendOutput(api *nvim.Nvim, lines []string) error {
+	s.windowMu.Lock()
+	defer s.windowMu.Unlock()
+
+	buf := make([][]byte, len(lines))
+	for i, line := range lines {
+		buf[i] = []byte(line)
+	}
+	return api.SetBufferLines(s.outputBuf, -1, -1, false, buf)
+}
+
+// outputWidth returns the width of the output window, or of the editor if it isn't open.
+func (s *pluginState) outputWidth(api *nvim.Nvim) int {
+	s.windowMu.Lock()
+	outputWin := s.outputWin
+	s.windowMu.Unlock()
+
+	if outputWin != 0 {
+		if width, err := api.WindowWidth(outputWin); err == nil {
+			return width
+		}
+	}
+
+	var columns int
+	if err := api.Option("columns", &columns); err != nil {
+		return math.MaxInt32
+	}
+	return columns
+}
+
 func (s *pluginState) drawSchemas(batch *nvim.Batch, shiftwidth int) int {
 	schemas := s.displayCache[s.db.CurrentName()]
 
@@ -148,22 +148,22 @@ func Test_pluginState_refreshCache(t *testing.T) {
 		Times(1)
 
 	mockdb.EXPECT().
-		ListSchemas().
+		ListSchemas(gomock.Any()).
 		Return([]string{"public", "private"}, error(nil)).
 		Times(1)
 
 	mockdb.EXPECT().
-		ListTables("public").
+		ListTables(gomock.Any(), "public").
 		Return([]string{"foo"}, error(nil)).
 		Times(1)
 
 	mockdb.EXPECT().
-		ListTables("private").
+		ListTables(gomock.Any(), "private").
 		Return([]string{"private.qux"}, error(nil)).
 		Times(1)
 
 	mockdb.EXPECT().
-		DescribeTable("foo").
+		DescribeTable(gomock.Any(), "foo").
 		Return(&dbman.TableSchema{
 			Name: "foo",
 			Columns: []dbman.ColumnSchema{
@@ -182,7 +182,7 @@ func Test_pluginState_refreshCache(t *testing.T) {
 		Times(1)
 
 	mockdb.EXPECT().
-		DescribeTable("private.qux").
+		DescribeTable(gomock.Any(), "private.qux").
 		Return(&dbman.TableSchema{
 			Name: "qux",
 			Columns: []dbman.ColumnSchema{
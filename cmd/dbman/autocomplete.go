@@ -1,30 +1,325 @@
 package main
 
-import "strings"
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
 
-var sqlGrammar = []byte{}
+	"dabbertorres.dev/dbman"
+)
 
-func autocomplete(line string, pos int, key rune) (string, int, bool) {
-	// tab means try autocompleting
-	if key != '\t' || /* TODO implement */ true {
+// sqlKeywords are completed anywhere in a statement.
+var sqlKeywords = []string{
+	"ADD", "ALL", "ALTER", "AND", "AS", "ASC", "BEGIN", "BETWEEN", "BY", "CASE", "CAST", "COLUMN",
+	"COMMIT", "CONSTRAINT", "COUNT", "CREATE", "CROSS", "DEFAULT", "DELETE", "DESC", "DISTINCT",
+	"DROP", "ELSE", "END", "EXISTS", "EXPLAIN", "FALSE", "FOREIGN", "FROM", "FULL", "GROUP", "HAVING",
+	"IN", "INDEX", "INNER", "INSERT", "INTERSECT", "INTO", "IS", "JOIN", "KEY", "LEFT", "LIKE",
+	"LIMIT", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER", "OUTER", "PRIMARY", "REFERENCES",
+	"RETURNING", "RIGHT", "ROLLBACK", "SCHEMA", "SELECT", "SET", "TABLE", "THEN", "TRUE",
+	"TRUNCATE", "UNION", "UNIQUE", "UPDATE", "USING", "VALUES", "VIEW", "WHEN", "WHERE", "WITH",
+}
+
+var sqlKeywordSet = func() map[string]bool {
+	set := make(map[string]bool, len(sqlKeywords))
+	for _, keyword := range sqlKeywords {
+		set[keyword] = true
+	}
+	return set
+}()
+
+// tableKeywords are followed by a table name.
+var tableKeywords = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true, "TRUNCATE": true,
+}
+
+// commandNames are the backslash commands, see cli.command.
+var commandNames = []string{
+	"active", "begin", "commit", "connections", "describe", "e", "format", "help", "history", "i",
+	"o", "pager", "pset", "quit", "rollback", "schemas", "set", "stats", "switch", "tables", "unset",
+	"w", "x",
+}
+
+// autocomplete completes the word before the cursor when tab is pressed, with
+// a backslash command (or its arguments), or in a statement, with a keyword, or
+// a schema, table, or column name.
+func (c *cli) autocomplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
 		return "", 0, false
 	}
 
-	var (
-		leading  string
-		word     string
-		trailing string
-	)
-	// autocomplete only the last word
-	sep := strings.LastIndexByte(line[:pos], ' ')
-	if sep != -1 {
-		leading = line[:sep]
-		word = line[sep+1 : pos]
-		trailing = line[:sep]
+	start := pos
+	for start > 0 && isWordByte(line[start-1]) {
+		start--
+	}
+	word := line[start:pos]
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	var candidates []string
+	if strings.HasPrefix(strings.TrimSpace(line), `\`) {
+		candidates = c.commandCompletions(ctx, line[:start], word)
 	} else {
-		word = line[:pos]
+		candidates = c.sqlCompletions(ctx, line, start, pos)
+	}
+	return c.complete(line, start, pos, candidates)
+}
+
+func (c *cli) commandCompletions(ctx context.Context, before, word string) []string {
+	if strings.HasSuffix(before, `\`) {
+		// the command itself, the backslash isn't part of the word
+		return commandNames
+	}
+
+	args := strings.Fields(before)
+	if len(args) != 1 {
+		return nil
+	}
+
+	switch strings.TrimPrefix(args[0], `\`) {
+	case "switch", "s":
+		names, _ := c.db.ListConnections()
+		return names
+
+	case "describe", "d":
+		return c.tableCompletions(ctx, word)
+
+	case "tables", "t":
+		return c.cache.Schemas(ctx)
+
+	case "format":
+		return append(dbman.Formats(), "insert", "update", "copy")
+
+	case "pset":
+		return dbman.DisplaySettings()
+
+	case "x", "pager":
+		return []string{"on", "off", "auto"}
+
+	default:
+		return nil
+	}
+}
+
+// sqlCompletions returns the candidates for the word at line[start:pos], in the
+// statement that it's part of, which may have started on previous lines.
+func (c *cli) sqlCompletions(ctx context.Context, line string, start, pos int) []string {
+	before := c.db.Dialect().SplitStatements(strings.Join(c.pending, "\n") + "\n" + line[:start] + " x")
+	after := c.db.Dialect().SplitStatements("x " + line[pos:])
+	// the current statement, without the word being completed
+	stmtBefore := strings.TrimSuffix(before[len(before)-1], "x")
+	stmt := stmtBefore + " " + strings.TrimPrefix(after[0], "x")
+
+	word := line[start:pos]
+	tokens := sqlTokens(stmtBefore)
+	refs := tableRefs(sqlTokens(stmt))
+
+	if dot := strings.LastIndexByte(word, '.'); dot >= 0 {
+		qualifier := word[:dot]
+		if !tableKeywords[contextKeyword(tokens)] {
+			if table, ok := refs[strings.ToLower(qualifier)]; ok {
+				return qualify(qualifier, c.cache.Columns(ctx, table))
+			}
+		}
+		return c.tableCompletions(ctx, word)
+	}
+
+	if tableKeywords[contextKeyword(tokens)] {
+		return c.tableCompletions(ctx, word)
+	}
+
+	// keywords, and the columns of the tables in the statement
+	candidates := append([]string(nil), sqlKeywords...)
+	if word != "" && strings.ToLower(word) == word {
+		for i, keyword := range candidates {
+			candidates[i] = strings.ToLower(keyword)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, table := range refs {
+		if !seen[table] {
+			seen[table] = true
+			candidates = append(candidates, c.cache.Columns(ctx, table)...)
+		}
+	}
+	return candidates
+}
+
+// tableCompletions returns the tables, and schemas, that word may be the start of.
+// If word is qualified by a schema, the tables in that schema are returned.
+func (c *cli) tableCompletions(ctx context.Context, word string) []string {
+	if dot := strings.LastIndexByte(word, '.'); dot >= 0 {
+		schema := word[:dot]
+		return qualify(schema, c.cache.Tables(ctx, schema))
+	}
+
+	candidates := append([]string(nil), c.cache.Tables(ctx, "")...)
+	for _, schema := range c.cache.Schemas(ctx) {
+		candidates = append(candidates, schema+".")
+	}
+	return candidates
+}
+
+// complete replaces line[start:pos] with the candidate that it's the start of, if
+// there's only one, otherwise with the start that they all have in common.
+// If that doesn't add anything, the candidates are listed.
+func (c *cli) complete(line string, start, pos int, candidates []string) (string, int, bool) {
+	word := strings.ToLower(line[start:pos])
+
+	seen := make(map[string]bool)
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), word) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+
+	var completion string
+	switch len(matches) {
+	case 0:
+		return "", 0, false
+
+	case 1:
+		completion = matches[0]
+		if !strings.HasSuffix(completion, ".") {
+			completion += " "
+		}
+
+	default:
+		completion = commonPrefix(matches)
+		if len(completion) <= len(word) {
+			c.println(strings.Join(matches, "  "))
+			return "", 0, false
+		}
+	}
+
+	newLine := line[:start] + completion + line[pos:]
+	return newLine, start + len(completion), true
+}
+
+// commonPrefix returns the longest (case-insensitive) prefix of all of words, as in the first word.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		n := 0
+		for n < len(prefix) && n < len(word) && unicode.ToLower(rune(prefix[n])) == unicode.ToLower(rune(word[n])) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return prefix
+}
+
+func qualify(qualifier string, names []string) []string {
+	qualified := make([]string, len(names))
+	for i, name := range names {
+		qualified[i] = qualifier + "." + name
+	}
+	return qualified
+}
+
+// isWordByte reports if c may be part of a completed word, which may be qualified (e.g. schema.table).
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+var identUnquoter = strings.NewReplacer(`"`, "", "`", "")
+
+// sqlTokens splits stmt into words (which may be qualified), and punctuation.
+// Quoted strings and comments are skipped, and quoted identifiers are unquoted.
+func sqlTokens(stmt string) []string {
+	var tokens []string
+	for i := 0; i < len(stmt); {
+		ch := stmt[i]
+		switch {
+		case unicode.IsSpace(rune(ch)):
+			i++
+
+		case ch == '-' && strings.HasPrefix(stmt[i:], "--"):
+			if end := strings.IndexByte(stmt[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(stmt)
+			}
+
+		case ch == '\'':
+			end := strings.IndexByte(stmt[i+1:], '\'')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 2
+
+		case ch == '"' || ch == '`' || isWordByte(ch):
+			end := i
+			for end < len(stmt) && (isWordByte(stmt[end]) || stmt[end] == '"' || stmt[end] == '`') {
+				end++
+			}
+			if token := identUnquoter.Replace(stmt[i:end]); token != "" {
+				tokens = append(tokens, token)
+			}
+			i = end
+
+		default:
+			tokens = append(tokens, string(ch))
+			i++
+		}
 	}
+	return tokens
+}
+
+// contextKeyword returns the keyword that the next word follows, i.e. the last
+// token, or for a list (e.g. FROM a, b), the keyword that started it.
+func contextKeyword(tokens []string) string {
+	i := len(tokens) - 1
+	for i > 0 && tokens[i] == "," && !isSQLKeyword(tokens[i-1]) {
+		i -= 2
+	}
+	if i >= 0 && isSQLKeyword(tokens[i]) {
+		return strings.ToUpper(tokens[i])
+	}
+	return ""
+}
+
+// tableRefs returns the tables in tokens, i.e. those following FROM, JOIN, etc.,
+// by their lower-cased names and aliases.
+func tableRefs(tokens []string) map[string]string {
+	refs := make(map[string]string)
+	for i := 0; i < len(tokens); i++ {
+		if !tableKeywords[strings.ToUpper(tokens[i])] {
+			continue
+		}
+
+		for i+1 < len(tokens) && !isSQLKeyword(tokens[i+1]) && isWordByte(tokens[i+1][0]) {
+			i++
+			table := tokens[i]
+			refs[strings.ToLower(table)] = table
+			if dot := strings.LastIndexByte(table, '.'); dot >= 0 {
+				refs[strings.ToLower(table[dot+1:])] = table
+			}
+
+			// an alias, optionally following AS
+			if i+1 < len(tokens) && strings.EqualFold(tokens[i+1], "AS") {
+				i++
+			}
+			if i+1 < len(tokens) && !isSQLKeyword(tokens[i+1]) && isWordByte(tokens[i+1][0]) {
+				i++
+				refs[strings.ToLower(tokens[i])] = table
+			}
+
+			// a list of tables, e.g. FROM a, b
+			if i+1 < len(tokens) && tokens[i+1] == "," {
+				i++
+				continue
+			}
+			break
+		}
+	}
+	return refs
+}
 
-	result := leading + " " + word + trailing
-	return result, len(result), true
+func isSQLKeyword(token string) bool {
+	return sqlKeywordSet[strings.ToUpper(token)]
 }
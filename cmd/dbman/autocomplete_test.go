@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_sqlTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want []string
+	}{
+		{name: "empty", stmt: "", want: nil},
+		{name: "words and punctuation", stmt: "SELECT a, b FROM t WHERE a = 1", want: []string{"SELECT", "a", ",", "b", "FROM", "t", "WHERE", "a", "=", "1"}},
+		{name: "qualified", stmt: "SELECT u.id FROM public.users u", want: []string{"SELECT", "u.id", "FROM", "public.users", "u"}},
+		{name: "quoted identifiers", stmt: "SELECT * FROM \"My Table\", `other`", want: []string{"SELECT", "*", "FROM", "My", "Table", ",", "other"}},
+		{name: "strings and comments", stmt: "SELECT 'FROM x' -- FROM y\nFROM z", want: []string{"SELECT", "FROM", "z"}},
+		{name: "unterminated string", stmt: "SELECT 'abc", want: []string{"SELECT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, sqlTokens(tt.stmt)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_tableRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want map[string]string
+	}{
+		{name: "none", stmt: "SELECT 1", want: map[string]string{}},
+		{name: "table", stmt: "SELECT * FROM Users", want: map[string]string{"users": "Users"}},
+		{name: "alias", stmt: "SELECT * FROM users u JOIN orders AS o ON o.user_id = u.id", want: map[string]string{"users": "users", "u": "users", "orders": "orders", "o": "orders"}},
+		{name: "qualified", stmt: "SELECT * FROM public.users", want: map[string]string{"public.users": "public.users", "users": "public.users"}},
+		{name: "list", stmt: "SELECT * FROM a, b x WHERE x.id = a.id", want: map[string]string{"a": "a", "b": "b", "x": "b"}},
+		{name: "update", stmt: "UPDATE users SET name = 'x'", want: map[string]string{"users": "users"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tableRefs(sqlTokens(tt.stmt))); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_contextKeyword(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{name: "empty", stmt: "", want: ""},
+		{name: "keyword", stmt: "SELECT * from", want: "FROM"},
+		{name: "after a word", stmt: "SELECT * FROM users", want: ""},
+		{name: "list", stmt: "SELECT * FROM a, b,", want: "FROM"},
+		{name: "select list", stmt: "SELECT a,", want: "SELECT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextKeyword(sqlTokens(tt.stmt)); got != tt.want {
+				t.Errorf("expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_commonPrefix(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  string
+	}{
+		{name: "one", words: []string{"users"}, want: "users"},
+		{name: "shared", words: []string{"user_roles", "users"}, want: "user"},
+		{name: "case insensitive", words: []string{"Users", "USER_ROLES"}, want: "User"},
+		{name: "nothing shared", words: []string{"orders", "users"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commonPrefix(tt.words); got != tt.want {
+				t.Errorf("expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
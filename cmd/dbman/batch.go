@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"dabbertorres.dev/dbman"
+	"golang.org/x/term"
+)
+
+// runBatch runs script against the named connection, without an interactive session.
+// Results are written to stdout in format. Notices, and summaries of statements
+// without results, are written to stderr, so that stdout only contains results.
+// The first statement to fail stops the script, and its error is returned.
+func runBatch(db *dbman.DBMan, connName, script, format string, display *dbman.Display) error {
+	defer db.Close()
+
+	if err := db.SwitchConnection(connName, batchPrompt); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	var stmt string // the statement being run
+	err := db.QueryScript(ctx, script, nil, func(result *dbman.StatementResult, rows *dbman.Rows) error {
+		stmt = result.Statement
+
+		for _, notice := range result.Notices {
+			fmt.Fprintln(os.Stderr, notice)
+		}
+		if result.Err != nil {
+			// returned by QueryScript
+			return nil
+		}
+
+		if rows == nil {
+			command := result.Command
+			if command == "" {
+				command = "OK"
+			}
+			fmt.Fprintf(os.Stderr, "%s (%s)\n", command, result.Duration.Round(time.Microsecond))
+			return nil
+		}
+
+		rw, err := dbman.NewResultWriter(out, format, rows.Columns, rows.ColumnTypes, display)
+		if err != nil {
+			return err
+		}
+		for batch := rows.Batch(streamBatchSize); len(batch) != 0; batch = rows.Batch(streamBatchSize) {
+			if err := rw.Write(batch); err != nil {
+				return err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := rw.Close(); err != nil {
+			return err
+		}
+
+		if rows.More() {
+			fmt.Fprintf(os.Stderr, "more rows available, only the first %d were written\n", rw.Count())
+		}
+		return nil
+	})
+	if err != nil {
+		if stmt != "" {
+			return fmt.Errorf("%s: %w", stmt, queryError(ctx, err))
+		}
+		return err
+	}
+
+	if db.InTransaction() {
+		fmt.Fprintln(os.Stderr, "warning: the script left a transaction open, it will be rolled back")
+	}
+
+	return out.Flush()
+}
+
+// batchScript returns the script to run in batch mode, from command, file, or stdin
+// (if it isn't a terminal). ok is false if an interactive session should be started instead.
+func batchScript(command, file string) (script string, ok bool, err error) {
+	switch {
+	case command != "" && file != "":
+		return "", false, errors.New("only one of -c and -f may be given")
+
+	case command != "":
+		return command, true, nil
+
+	case file == "-":
+		buf, err := ioutil.ReadAll(os.Stdin)
+		return string(buf), true, err
+
+	case file != "":
+		buf, err := ioutil.ReadFile(file)
+		return string(buf), true, err
+
+	case !term.IsTerminal(0):
+		buf, err := ioutil.ReadAll(os.Stdin)
+		return string(buf), true, err
+
+	default:
+		return "", false, nil
+	}
+}
+
+// batchPrompt reads answers from the terminal, if there is one.
+// Otherwise, anything that requires a prompt (e.g. a password that isn't in the
+// config file or environment, or confirm_writes) fails.
+func batchPrompt(user, instruction string, questions []string, echos []bool) ([]string, error) {
+	if !term.IsTerminal(0) {
+		if len(questions) == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't prompt for '%s' without a terminal", strings.TrimSpace(questions[0]))
+	}
+
+	if user != "" || instruction != "" {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", user, instruction)
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	answers := make([]string, len(questions))
+	for i, question := range questions {
+		fmt.Fprint(os.Stderr, question)
+
+		if echos[i] {
+			line, err := stdin.ReadString('\n')
+			if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+				return nil, err
+			}
+			answers[i] = strings.TrimRight(line, "\r\n")
+		} else {
+			answer, err := term.ReadPassword(0)
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = string(answer)
+		}
+	}
+	return answers, nil
+}
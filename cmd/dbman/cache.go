@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"dabbertorres.dev/dbman"
+)
+
+// completionTimeout limits how long each completion waits on the database, in total.
+const completionTimeout = 2 * time.Second
+
+// schemaCache holds the active connection's schemas, tables, and columns, for completion.
+// Each is only queried for when first needed, until the cache is reset (e.g. by DDL).
+// Failed queries are cached as empty, so that completion doesn't keep waiting on them,
+// unless they failed because ctx was done (e.g. completion ran out of time), so that
+// they're tried again by the next completion. Nothing is queried for once ctx is done.
+type schemaCache struct {
+	db      *dbman.DBMan
+	schemas []string            // nil until queried
+	tables  map[string][]string // by schema, "" for the default schema(s)
+	columns map[string][]string // by table name, as written
+}
+
+func newSchemaCache(db *dbman.DBMan) *schemaCache {
+	c := &schemaCache{db: db}
+	c.reset()
+	return c
+}
+
+func (c *schemaCache) reset() {
+	c.schemas = nil
+	c.tables = make(map[string][]string)
+	c.columns = make(map[string][]string)
+}
+
+func (c *schemaCache) Schemas(ctx context.Context) []string {
+	if c.schemas == nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		schemas, err := c.db.ListSchemas(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			schemas = []string{}
+		}
+		c.schemas = schemas
+	}
+	return c.schemas
+}
+
+// Tables returns the tables in schema, or in the default schema(s) if it's empty.
+func (c *schemaCache) Tables(ctx context.Context, schema string) []string {
+	key := strings.ToLower(schema)
+	tables, ok := c.tables[key]
+	if !ok {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var err error
+		tables, err = c.db.ListTables(ctx, schema)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			tables = []string{}
+		}
+		c.tables[key] = tables
+	}
+	return tables
+}
+
+// Columns returns the names of table's columns. table may be schema qualified.
+func (c *schemaCache) Columns(ctx context.Context, table string) []string {
+	key := strings.ToLower(table)
+	columns, ok := c.columns[key]
+	if !ok {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		schema, err := c.db.DescribeTable(ctx, table)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			schema = &dbman.TableSchema{}
+		}
+		columns = []string{}
+		for _, col := range schema.Columns {
+			columns = append(columns, col.Name)
+		}
+		c.columns[key] = columns
+	}
+	return columns
+}
+
+// isDDL reports if a statement's command tag (e.g. "CREATE TABLE") shows that it
+// may have changed the schema.
+func isDDL(command string) bool {
+	switch strings.SplitN(command, " ", 2)[0] {
+	case "CREATE", "ALTER", "DROP", "RENAME", "USE":
+		return true
+	default:
+		return false
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 
 	"dabbertorres.dev/dbman"
 	"golang.org/x/crypto/ssh"
@@ -14,22 +23,102 @@ import (
 )
 
 type cli struct {
-	terminal *term.Terminal
-	db       *dbman.DBMan
-	prompter ssh.KeyboardInteractiveChallenge
-	running  bool
+	rw          *combinedReaderWriter
+	terminal    *term.Terminal
+	db          *dbman.DBMan
+	prompter    ssh.KeyboardInteractiveChallenge
+	running     bool
+	quitWarned  bool // warned about uncommitted transactions
+	vars        map[string]string
+	history     *history
+	cache       *schemaCache // for completion
+	pending     []string     // lines of a statement that hasn't been terminated yet
+	lastQuery   string
+	search      historySearch // the last reverse search, continued by searching again
+	format      string        // how results are written, see dbman.Formats
+	formatArgs  []string      // e.g. the table to generate INSERTs for
+	display     dbman.Display
+	expanded    string   // on, off, or auto: tables are written expanded if wider than the terminal
+	pager       string   // on, off, or auto: results taller than the terminal are shown with $PAGER
+	output      *os.File // if set, results are written here rather than to the terminal
+	fd          int
+	cookedState *term.State // terminal state before entering raw mode
+	rawState    *term.State // set while interruptible, to return to raw mode for prompts
 }
 
-func newCLI(terminal *term.Terminal, db *dbman.DBMan) *cli {
-	return &cli{
-		terminal: terminal,
-		db:       db,
-		prompter: dbman.PasswordPrompt(terminal),
-		running:  true,
+func newCLI(rw *combinedReaderWriter, db *dbman.DBMan, display dbman.Display, fd int, cookedState *term.State) *cli {
+	c := &cli{
+		rw:          rw,
+		db:          db,
+		running:     true,
+		vars:        make(map[string]string),
+		cache:       newSchemaCache(db),
+		format:      "table",
+		display:     display,
+		expanded:    "auto",
+		pager:       "auto",
+		fd:          fd,
+		cookedState: cookedState,
+	}
+	c.newTerminal(&history{})
+	return c
+}
+
+// newTerminal replaces the terminal with one that can recall h's entries.
+func (c *cli) newTerminal(h *history) {
+	entries := h.entries
+	if len(entries) > maxTerminalHistory {
+		entries = entries[len(entries)-maxTerminalHistory:]
+	}
+
+	// the terminal's history can only be added to by entering lines, so replay them
+	var replay strings.Builder
+	for _, entry := range entries {
+		replay.WriteString(historyLine(entry) + "\r")
+	}
+
+	in, out := c.rw.Reader, c.rw.Writer
+	c.rw.Reader, c.rw.Writer = strings.NewReader(replay.String()), ioutil.Discard
+
+	terminal := term.NewTerminal(c.rw, "> ")
+	for range entries {
+		if _, err := terminal.ReadLine(); err != nil && err != term.ErrPasteIndicator {
+			break
+		}
+	}
+
+	c.rw.Reader, c.rw.Writer = in, out
+	terminal.AutoCompleteCallback = c.keyPress
+
+	c.terminal = terminal
+	c.history = h
+	c.search = historySearch{}
+	c.prompter = c.rawPrompt(dbman.PasswordPrompt(terminal))
+	c.updatePrompt()
+}
+
+// loadHistory switches to the active connection's history.
+func (c *cli) loadHistory() {
+	h, err := loadHistory(c.db.CurrentName())
+	if err != nil {
+		c.println("failed to load history:", err)
+	}
+	c.newTerminal(h)
+}
+
+func (c *cli) addHistory(entry string) {
+	if err := c.history.add(entry); err != nil {
+		c.println("failed to save history:", err)
 	}
 }
 
 func (c *cli) Close() error {
+	// just in case it is still set when we exit
+	c.terminal.SetBracketedPasteMode(false)
+
+	if c.output != nil {
+		c.output.Close()
+	}
 	return c.db.Close()
 }
 
@@ -44,6 +133,54 @@ func (c *cli) printf(format string, args ...interface{}) {
 	fmt.Fprintf(c.terminal, format, args...)
 }
 
+// interruptible returns a context that is canceled by Ctrl-C, until stop is called.
+// The terminal leaves raw mode until then, so that Ctrl-C is delivered as SIGINT.
+func (c *cli) interruptible() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if c.cookedState != nil {
+		if state, err := term.GetState(c.fd); err == nil {
+			if err := term.Restore(c.fd, c.cookedState); err == nil {
+				c.rawState = state
+			}
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+		if c.rawState != nil {
+			term.Restore(c.fd, c.rawState)
+			c.rawState = nil
+		}
+	}
+}
+
+// rawPrompt wraps prompter to return to raw mode while prompting, e.g. to confirm
+// a write in the middle of a query.
+func (c *cli) rawPrompt(prompter ssh.KeyboardInteractiveChallenge) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		if c.rawState != nil {
+			if state, err := term.GetState(c.fd); err == nil {
+				if err := term.Restore(c.fd, c.rawState); err == nil {
+					defer term.Restore(c.fd, state)
+				}
+			}
+		}
+		return prompter(user, instruction, questions, echos)
+	}
+}
+
 func (c *cli) run(initialConnection string) {
 	defer c.Close()
 
@@ -51,23 +188,44 @@ func (c *cli) run(initialConnection string) {
 		if err := c.db.SwitchConnection(initialConnection, c.prompter); err != nil {
 			log.Fatal(err)
 		}
+		c.loadHistory()
 	}
 
 	for c.running {
 		line, err := c.terminal.ReadLine()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				return
+				// Ctrl-D quits, and Ctrl-C or Ctrl-D discards an unterminated statement.
+				// The terminal would keep returning io.EOF, so is replaced if we carry on.
+				var quitErr error
+				if len(c.pending) == 0 {
+					if quitErr = c.quit(); quitErr == nil {
+						return
+					}
+				}
+				io.WriteString(c.rw, "\r\n")
+				c.pending = nil
+				c.newTerminal(c.history)
+				if quitErr != nil {
+					c.println(quitErr)
+				}
+				continue
 			}
 			if err != term.ErrPasteIndicator {
 				c.println(err)
 				continue
 			}
 		}
-		line = strings.TrimSpace(line)
 
-		if line[0] == '\\' {
-			args := strings.Split(line[1:], " ")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" && len(c.pending) == 0:
+			continue
+
+		case strings.HasPrefix(trimmed, "\\"):
+			c.addHistory(trimmed)
+
+			args := strings.Split(trimmed[1:], " ")
 			for i := 0; i < len(args); i++ {
 				arg := strings.TrimSpace(args[i])
 				if arg == "" {
@@ -81,12 +239,40 @@ func (c *cli) run(initialConnection string) {
 			if err := c.command(args); err != nil {
 				c.println(err)
 			}
-		} else {
-			if err := c.query(line); err != nil {
+
+		default:
+			if len(c.pending) == 0 {
+				line = c.history.expand(line)
+			}
+
+			// statements continue until terminated by a semicolon
+			c.pending = append(c.pending, line)
+			script := strings.Join(c.pending, "\n")
+			if !c.db.Dialect().IsTerminated(script) {
+				c.updatePrompt()
+				continue
+			}
+
+			c.pending = nil
+			if err := c.execute(script); err != nil {
 				c.println(err)
 			}
 		}
+
+		c.updatePrompt()
+	}
+}
+
+// updatePrompt indicates if a transaction is open, and if a statement is being continued.
+func (c *cli) updatePrompt() {
+	prompt := "> "
+	if len(c.pending) != 0 {
+		prompt = "-> "
+	}
+	if c.db.InTransaction() {
+		prompt = "(tx)" + prompt
 	}
+	c.terminal.SetPrompt(prompt)
 }
 
 func (c *cli) command(args []string) error {
@@ -109,16 +295,57 @@ func (c *cli) command(args []string) error {
 	case "describe", "d":
 		return c.describeTable(args[1:])
 
+	case "begin":
+		return c.begin(args[1:])
+
+	case "commit":
+		return c.commit(args[1:])
+
+	case "rollback":
+		return c.rollback(args[1:])
+
+	case "set":
+		return c.setVar(args[1:])
+
+	case "unset":
+		return c.unsetVar(args[1:])
+
+	case "format":
+		return c.setFormat(args[1:])
+
+	case "o":
+		return c.setOutput(args[1:])
+
+	case "pset":
+		return c.setDisplay(args[1:])
+
+	case "x":
+		return c.setExpanded(args[1:])
+
+	case "pager":
+		return c.setPager(args[1:])
+
+	case "e":
+		return c.edit(args[1:])
+
+	case "i":
+		return c.include(args[1:])
+
+	case "w":
+		return c.writeQuery(args[1:])
+
 	case "stats":
 		return c.printStats(args[1:])
 
+	case "history":
+		return c.printHistory(args[1:])
+
 	case "help", "h", "?":
 		c.help()
 		return nil
 
 	case "quit", "q":
-		c.running = false
-		return nil
+		return c.quit()
 
 	default:
 		return errors.New("unknown command")
@@ -138,7 +365,32 @@ func (c *cli) help() {
 	c.println(`\schemas (\sn): print a list of accessible schemas (if relevant for current connection).`)
 	c.println(`\describe (\d): print the schema of a given table. To specify a non-public table, use <schema>.<table> syntax.`)
 	c.println()
+	c.println(`Transactions:`)
+	c.println(`\begin: open a transaction on the active connection. Statements run within it until it is committed or rolled back.`)
+	c.println(`\commit: commit the open transaction.`)
+	c.println(`\rollback: roll back the open transaction.`)
+	c.println()
+	c.println(`Variables:`)
+	c.println(`\set: set a variable, e.g. \set id 42, to be bound to :id (or \set 1 42 for $1) in queries. With no arguments, print all variables.`)
+	c.println(`\unset: remove a variable.`)
+	c.println()
+	c.println(`Query buffer:`)
+	c.println(`\e: edit the statement being entered, or the last query, with $VISUAL or $EDITOR (or vi). It's run when the editor exits, if terminated by a semicolon.`)
+	c.println(`    \e <file>: edit the file, and run it when the editor exits.`)
+	c.println(`\i: run the statements in the given file.`)
+	c.println(`\w: write the statement being entered, or the last query, to the given file.`)
+	c.println()
+	c.println(`Output:`)
+	c.println(`\format: set the format results are written in (` + strings.Join(dbman.Formats(), ", ") + `). With no arguments, print the current format.`)
+	c.println(`    \format insert <table>, or \format copy <table>: write results as INSERT statements, or a COPY block (postgres only), for the table.`)
+	c.println(`    \format update <table> <key column>[,...]: write results as UPDATE statements of the table, matching rows by the key columns.`)
+	c.println(`\o: write results to the given file, rather than the terminal. With no arguments, return to the terminal.`)
+	c.println(`\x: toggle expanded output, where each row is written as a block of "column | value" lines. \x auto (the default) expands tables wider than the terminal.`)
+	c.println(`\pager: toggle showing results with $PAGER (or less -S). \pager auto (the default) only does for results taller than the terminal.`)
+	c.println(`\pset: set how values are displayed, e.g. \pset null ∅ (` + strings.Join(dbman.DisplaySettings(), ", ") + `). '' resets a setting. With no arguments, print all settings.`)
+	c.println()
 	c.println(`Extra:`)
+	c.println(`\history: print the active connection's history, or only the given number of most recent entries.`)
 	c.println(`\stats: print stats about the current database connection`)
 	c.println(`\help (\h, \?): print this dialog.`)
 	c.println(`\quit (\q): exit.`)
@@ -172,7 +424,47 @@ func (c *cli) switchConnection(args []string) error {
 		return errors.New("a single connection name must be specified")
 	}
 
-	return c.db.SwitchConnection(args[0], c.prompter)
+	if c.db.InTransaction() {
+		c.printf("warning: '%s' has an open transaction, which stays open until committed or rolled back", c.db.CurrentName())
+	}
+
+	if err := c.db.SwitchConnection(args[0], c.prompter); err != nil {
+		return err
+	}
+	c.cache.reset()
+	c.loadHistory()
+	return nil
+}
+
+func (c *cli) begin(args []string) error {
+	ctx, stop := c.interruptible()
+	defer stop()
+
+	return queryError(ctx, c.db.Begin(ctx))
+}
+
+func (c *cli) commit(args []string) error {
+	ctx, stop := c.interruptible()
+	defer stop()
+
+	return queryError(ctx, c.db.Commit(ctx))
+}
+
+func (c *cli) rollback(args []string) error {
+	ctx, stop := c.interruptible()
+	defer stop()
+
+	return queryError(ctx, c.db.Rollback(ctx))
+}
+
+func (c *cli) quit() error {
+	if open := c.db.OpenTransactions(); len(open) != 0 && !c.quitWarned {
+		c.quitWarned = true
+		return fmt.Errorf("uncommitted transaction on: %s (\\quit, or Ctrl-D, again to exit anyway, rolling them back)", strings.Join(open, ", "))
+	}
+
+	c.running = false
+	return nil
 }
 
 func (c *cli) listTables(args []string) error {
@@ -181,7 +473,10 @@ func (c *cli) listTables(args []string) error {
 		schema = args[0]
 	}
 
-	tables, err := c.db.ListTables(schema)
+	ctx, stop := c.interruptible()
+	defer stop()
+
+	tables, err := c.db.ListTables(ctx, schema)
 	if err != nil {
 		return err
 	}
@@ -194,7 +489,10 @@ func (c *cli) listTables(args []string) error {
 }
 
 func (c *cli) listSchemas(args []string) error {
-	schemas, err := c.db.ListSchemas()
+	ctx, stop := c.interruptible()
+	defer stop()
+
+	schemas, err := c.db.ListSchemas(ctx)
 	if err != nil {
 		return err
 	}
@@ -211,8 +509,11 @@ func (c *cli) describeTable(args []string) error {
 		return errors.New("'\\describe' requires at least one table name")
 	}
 
+	ctx, stop := c.interruptible()
+	defer stop()
+
 	for _, name := range args {
-		schema, err := c.db.DescribeTable(name)
+		schema, err := c.db.DescribeTable(ctx, name)
 		if err != nil {
 			return err
 		}
@@ -229,6 +530,158 @@ func (c *cli) describeTable(args []string) error {
 	return nil
 }
 
+func (c *cli) setVar(args []string) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(c.vars))
+		for name := range c.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			c.printf("%s = '%s'", name, c.vars[name])
+		}
+		return nil
+	}
+
+	c.vars[args[0]] = strings.Join(args[1:], " ")
+	return nil
+}
+
+func (c *cli) unsetVar(args []string) error {
+	if len(args) != 1 {
+		return errors.New("a single variable name must be specified")
+	}
+
+	delete(c.vars, args[0])
+	return nil
+}
+
+func (c *cli) lookupVar(name string) (string, error) {
+	value, ok := c.vars[name]
+	if !ok {
+		return "", fmt.Errorf("no value for '%s', use '\\set %[1]s <value>'", name)
+	}
+	return value, nil
+}
+
+func (c *cli) setFormat(args []string) error {
+	if len(args) == 0 {
+		c.println(strings.Join(append([]string{c.format}, c.formatArgs...), " "))
+		return nil
+	}
+
+	switch format := strings.ToLower(args[0]); format {
+	case "insert", "copy":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: \\format %s <table>", format)
+		}
+
+	case "update":
+		if len(args) != 3 {
+			return errors.New(`usage: \format update <table> <key column>[,<key column>...]`)
+		}
+
+	default:
+		if _, ok := dbman.LookupFormat(format); !ok {
+			return fmt.Errorf("unknown format, must be one of: %s, insert, update, copy", strings.Join(dbman.Formats(), ", "))
+		}
+		args = args[:1]
+	}
+
+	c.format = strings.ToLower(args[0])
+	c.formatArgs = args[1:]
+	return nil
+}
+
+func (c *cli) setDisplay(args []string) error {
+	switch len(args) {
+	case 0:
+		for _, name := range dbman.DisplaySettings() {
+			value, _ := c.display.Get(name)
+			c.printf("%s: %s", name, value)
+		}
+		return nil
+
+	case 1:
+		value, err := c.display.Get(args[0])
+		if err != nil {
+			return err
+		}
+		c.println(value)
+		return nil
+
+	default:
+		value := strings.Join(args[1:], " ")
+		if value == "''" {
+			value = ""
+		}
+		return c.display.Set(args[0], value)
+	}
+}
+
+func (c *cli) setExpanded(args []string) error {
+	switch {
+	case len(args) == 0 && c.expanded == "on":
+		c.expanded = "off"
+
+	case len(args) == 0:
+		c.expanded = "on"
+
+	default:
+		switch mode := strings.ToLower(args[0]); mode {
+		case "on", "off", "auto":
+			c.expanded = mode
+		default:
+			return errors.New(`usage: \x [on|off|auto]`)
+		}
+	}
+
+	c.printf("expanded display is %s", c.expanded)
+	return nil
+}
+
+func (c *cli) setPager(args []string) error {
+	switch {
+	case len(args) == 0 && c.pager == "on":
+		c.pager = "off"
+
+	case len(args) == 0:
+		c.pager = "on"
+
+	default:
+		switch mode := strings.ToLower(args[0]); mode {
+		case "on", "off", "auto":
+			c.pager = mode
+		default:
+			return errors.New(`usage: \pager [on|off|auto]`)
+		}
+	}
+
+	c.printf("pager is %s", c.pager)
+	return nil
+}
+
+func (c *cli) setOutput(args []string) error {
+	if c.output != nil {
+		if err := c.output.Close(); err != nil {
+			c.println("failed to close output file:", err)
+		}
+		c.output = nil
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	f, err := os.Create(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	c.output = f
+	return nil
+}
+
 func (c *cli) printStats(args []string) error {
 	// ignore arguments
 	stats := c.db.Stats()
@@ -249,31 +702,320 @@ func (c *cli) printStats(args []string) error {
 	return nil
 }
 
+func (c *cli) printHistory(args []string) error {
+	entries := c.history.entries
+	start := 0
+	if len(args) != 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return errors.New(`usage: \history [count]`)
+		}
+		if n < len(entries) {
+			start = len(entries) - n
+		}
+	}
+
+	for i := start; i < len(entries); i++ {
+		c.printf("%5d  %s", i+1, strings.ReplaceAll(entries[i], "\n", "\n       "))
+	}
+	return nil
+}
+
+// currentQuery returns the statement being entered, if any, otherwise the last query run.
+func (c *cli) currentQuery() string {
+	if len(c.pending) != 0 {
+		return strings.Join(c.pending, "\n")
+	}
+	return c.lastQuery
+}
+
+func (c *cli) edit(args []string) error {
+	var text string
+	if len(args) == 0 {
+		var err error
+		if text, err = editText(c.currentQuery(), c.fd, c.cookedState); err != nil {
+			return err
+		}
+	} else {
+		path := strings.Join(args, " ")
+		if err := editFile(path, c.fd, c.cookedState); err != nil {
+			return err
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// not saved
+			return nil
+		} else if err != nil {
+			return err
+		}
+		text = string(buf)
+	}
+
+	c.pending = nil
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	c.println(text)
+
+	if !c.db.Dialect().IsTerminated(text) {
+		// continue entering the statement
+		c.pending = strings.Split(text, "\n")
+		return nil
+	}
+	return c.execute(text)
+}
+
+func (c *cli) include(args []string) error {
+	if len(args) == 0 {
+		return errors.New(`usage: \i <file>`)
+	}
+
+	buf, err := ioutil.ReadFile(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	return c.query(string(buf))
+}
+
+func (c *cli) writeQuery(args []string) error {
+	if len(args) == 0 {
+		return errors.New(`usage: \w <file>`)
+	}
+
+	query := c.currentQuery()
+	if query == "" {
+		return errors.New("there isn't a query to write")
+	}
+	return ioutil.WriteFile(strings.Join(args, " "), []byte(query+"\n"), 0644)
+}
+
+// execute runs script, recording it as the last query, and in the history.
+func (c *cli) execute(script string) error {
+	c.lastQuery = script
+	c.addHistory(script)
+	return c.query(script)
+}
+
 func (c *cli) query(line string) error {
-	result, err := c.db.Query(line)
+	ctx, stop := c.interruptible()
+	defer stop()
+
+	first := true
+	err := c.db.QueryScript(ctx, line, c.lookupVar, func(result *dbman.StatementResult, rows *dbman.Rows) error {
+		if !first {
+			c.println()
+		}
+		first = false
+
+		for _, notice := range result.Notices {
+			c.println(notice)
+		}
+		if result.Err != nil {
+			// returned by QueryScript
+			return nil
+		}
+
+		if isDDL(result.Command) {
+			c.cache.reset()
+		}
+		return c.printResult(result, rows)
+	})
+	return queryError(ctx, err)
+}
+
+// streamBatchSize is how many rows are formatted and written at a time.
+const streamBatchSize = 100
+
+// printResult writes rows to c.output, or the terminal, as they're read, followed by a summary.
+func (c *cli) printResult(result *dbman.StatementResult, rows *dbman.Rows) error {
+	if rows == nil {
+		command := result.Command
+		if command == "" {
+			command = "OK"
+		}
+		c.printf("%s (%s)", command, result.Duration.Round(time.Microsecond))
+		return nil
+	}
+
+	start := time.Now()
+
+	var (
+		count int
+		err   error
+	)
+	if c.output != nil {
+		count, err = c.writeRows(c.output, rows)
+	} else {
+		w := c.pagedWriter()
+		count, err = c.writeRows(w, rows)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if errors.Is(err, errPagerClosed) {
+			// quit before reading everything, so there's no need for the rest
+			return nil
+		}
+	}
 	if err != nil {
 		return err
 	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-	marks := make([]string, len(result.Columns))
-	for i := range marks {
-		marks[i] = " %s"
+	duration := (result.Duration + time.Since(start)).Round(time.Microsecond)
+	if rows.More() {
+		c.printf("(more rows available, only the first %d are shown)", count)
 	}
-	printFmt := strings.Join(marks, "\t") + "\n"
+	c.printf("(%d rows, %s)", count, duration)
+	return nil
+}
 
-	writer := tabwriter.NewWriter(c.terminal, 2, 2, 1, ' ', tabwriter.Debug)
+// pagedWriter returns a writer to the terminal, that uses the pager depending on c.pager.
+func (c *cli) pagedWriter() *pagedWriter {
+	_, height, err := term.GetSize(c.fd)
+	if err != nil || height <= 0 {
+		// there's no screen to fill
+		height = math.MaxInt32
+	}
 
-	colNames := make([]interface{}, len(result.Columns))
-	for i, col := range result.Columns {
-		colNames[i] = col
+	return &pagedWriter{
+		terminal:    c.terminal,
+		mode:        c.pager,
+		height:      height,
+		fd:          c.fd,
+		cookedState: c.cookedState,
 	}
-	length, _ := fmt.Fprintf(writer, printFmt, colNames...)
-	fmt.Fprintln(writer, strings.Repeat("-", length))
+}
+
+// rowWriter writes a result a batch of rows at a time, like dbman.ResultWriter.
+type rowWriter interface {
+	Write(rows [][]interface{}) error
+	Count() int
+	Close() error
+}
 
-	for _, row := range result.Rows {
-		fmt.Fprintf(writer, printFmt, row...)
+// writeRows writes rows to w in c.format, a batch at a time, and returns how many were written.
+func (c *cli) writeRows(w io.Writer, rows *dbman.Rows) (int, error) {
+	batch := rows.Batch(streamBatchSize)
+
+	rw, err := c.rowWriter(w, rows, batch)
+	if err != nil {
+		return 0, err
+	}
+	for len(batch) != 0 {
+		if err := rw.Write(batch); err != nil {
+			return rw.Count(), err
+		}
+		batch = rows.Batch(streamBatchSize)
 	}
+	return rw.Count(), rw.Close()
+}
+
+// rowWriter returns a rowWriter for c.format. first is the first batch of rows,
+// which decides if tables are expanded, when c.expanded is auto.
+func (c *cli) rowWriter(w io.Writer, rows *dbman.Rows, first [][]interface{}) (rowWriter, error) {
+	dialect := c.db.Dialect()
+	switch c.format {
+	case "insert":
+		return newDialectWriter(w, rows, func(w io.Writer, result *dbman.QueryResult) error {
+			return dialect.WriteInserts(w, c.formatArgs[0], result)
+		}), nil
+
+	case "update":
+		return newDialectWriter(w, rows, func(w io.Writer, result *dbman.QueryResult) error {
+			return dialect.WriteUpdates(w, c.formatArgs[0], strings.Split(c.formatArgs[1], ","), result)
+		}), nil
+
+	case "copy":
+		return dialect.NewCopyWriter(w, c.formatArgs[0], rows.Columns, rows.ColumnTypes)
+
+	case "table":
+		format, err := c.tableFormat(rows, first)
+		if err != nil {
+			return nil, err
+		}
+		return dbman.NewResultWriter(w, format, rows.Columns, rows.ColumnTypes, &c.display)
+
+	default:
+		return dbman.NewResultWriter(w, c.format, rows.Columns, rows.ColumnTypes, &c.display)
+	}
+}
+
+// tableFormat returns table, or expanded, depending on c.expanded.
+// When it's auto, tables are expanded if the first batch of rows is wider than the terminal.
+func (c *cli) tableFormat(rows *dbman.Rows, first [][]interface{}) (string, error) {
+	switch c.expanded {
+	case "on":
+		return "expanded", nil
+
+	case "auto":
+		// only the terminal has a width to exceed
+		width, _, err := term.GetSize(c.fd)
+		if c.output != nil || err != nil || width == 0 {
+			break
+		}
+
+		var sb strings.Builder
+		result := dbman.QueryResult{Columns: rows.Columns, ColumnTypes: rows.ColumnTypes, Rows: first}
+		if err := dbman.WriteResult(&sb, "table", &result, &c.display); err != nil {
+			return "", err
+		}
+		for _, line := range strings.Split(sb.String(), "\n") {
+			if utf8.RuneCountInString(line) > width {
+				return "expanded", nil
+			}
+		}
+	}
+
+	return "table", nil
+}
 
-	// don't want to write anything until we're done (and successful)
-	return writer.Flush()
+// dialectWriter writes the SQL generated for each batch of rows, e.g. INSERTs.
+type dialectWriter struct {
+	w       io.Writer
+	write   func(w io.Writer, result *dbman.QueryResult) error
+	result  dbman.QueryResult
+	count   int
+	started bool
+}
+
+func newDialectWriter(w io.Writer, rows *dbman.Rows, write func(w io.Writer, result *dbman.QueryResult) error) *dialectWriter {
+	return &dialectWriter{
+		w:      w,
+		write:  write,
+		result: dbman.QueryResult{Columns: rows.Columns, ColumnTypes: rows.ColumnTypes},
+	}
+}
+
+func (d *dialectWriter) Write(rows [][]interface{}) error {
+	d.started = true
+	d.result.Rows = rows
+	if err := d.write(d.w, &d.result); err != nil {
+		return err
+	}
+	d.count += len(rows)
+	return nil
+}
+
+func (d *dialectWriter) Count() int {
+	return d.count
+}
+
+// Close writes for an empty result, if nothing was written, e.g. to check the key columns of UPDATEs.
+func (d *dialectWriter) Close() error {
+	if !d.started {
+		return d.Write(nil)
+	}
+	return nil
+}
+
+// queryError replaces the driver's error if the query was interrupted.
+func queryError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return errors.New("query canceled")
+	}
+	return err
 }
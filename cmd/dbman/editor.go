@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const defaultEditor = "vi"
+
+// editorCommand returns the command in $VISUAL or $EDITOR, or vi if neither are set.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) != 0 {
+			return args
+		}
+	}
+	return []string{defaultEditor}
+}
+
+// editFile opens path with the editor, and waits for it to exit.
+func editFile(path string, fd int, cookedState *term.State) error {
+	args := editorCommand()
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	defer cookedMode(fd, cookedState)()
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+	return nil
+}
+
+// editText opens text in the editor, in a temporary file, and returns it as saved.
+func editText(text string, fd int, cookedState *term.State) (string, error) {
+	f, err := ioutil.TempFile("", "dbman-*.sql")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if err := editFile(f.Name(), fd, cookedState); err != nil {
+		return "", err
+	}
+
+	buf, err := ioutil.ReadFile(f.Name())
+	return string(buf), err
+}
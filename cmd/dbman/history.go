@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dabbertorres.dev/dbman"
+)
+
+// maxHistory is how many entries are kept in each connection's history file.
+const maxHistory = 1000
+
+// historyDir is where each connection's history is saved, in a file named after it.
+var historyDir = filepath.Join(filepath.Dir(dbman.DefaultConfigFile), "history")
+
+// historyEscaper keeps each entry, which may be a multi-line statement, on one line of the file.
+var historyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// history is the statements and commands entered while connected to a connection.
+type history struct {
+	path    string // if empty, entries aren't saved
+	entries []string
+}
+
+// loadHistory reads connName's saved history.
+func loadHistory(connName string) (*history, error) {
+	h := &history{path: filepath.Join(historyDir, url.PathEscape(connName))}
+
+	f, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescapeHistory(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return h, err
+	}
+
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		return h, h.save()
+	}
+	return h, nil
+}
+
+// add appends entry to the history, and its file.
+func (h *history) add(entry string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || (len(h.entries) != 0 && h.entries[len(h.entries)-1] == entry) {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(historyDir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(historyEscaper.Replace(entry) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// save rewrites the history file with the current entries.
+func (h *history) save() error {
+	var sb strings.Builder
+	for _, entry := range h.entries {
+		sb.WriteString(historyEscaper.Replace(entry) + "\n")
+	}
+	return ioutil.WriteFile(h.path, []byte(sb.String()), 0600)
+}
+
+// search returns the index of the most recent entry before index before that
+// contains text, when shown on one line. ok is false if there isn't one.
+func (h *history) search(text string, before int) (index int, ok bool) {
+	for i := before - 1; i >= 0; i-- {
+		if strings.Contains(historyLine(h.entries[i]), text) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// expand returns the entry that line was recalled from, if it was collapsed
+// onto a single line by historyLine. Otherwise, line is returned.
+func (h *history) expand(line string) string {
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if entry := h.entries[i]; strings.Contains(entry, "\n") && historyLine(entry) == line {
+			return entry
+		}
+	}
+	return line
+}
+
+// historyLine collapses a multi-line entry onto a single line, so that it can
+// be recalled by the terminal.
+func historyLine(entry string) string {
+	return strings.ReplaceAll(entry, "\n", " ")
+}
+
+func unescapeHistory(line string) string {
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			i++
+			if line[i] == 'n' {
+				sb.WriteByte('\n')
+				continue
+			}
+		}
+		sb.WriteByte(line[i])
+	}
+	return sb.String()
+}
+
+// maxTerminalHistory is how many entries the terminal can recall, with the up and down keys.
+const maxTerminalHistory = 100
+
+// keyCtrlR starts, or continues, a reverse search of the history.
+const keyCtrlR = 'r' & 0x1f
+
+// historySearch is the state of a reverse search.
+type historySearch struct {
+	text  string // what's being searched for
+	index int    // of the entry matched
+	match string // the matched entry, as shown
+}
+
+// keyPress handles the keys that the terminal doesn't.
+func (c *cli) keyPress(line string, pos int, key rune) (string, int, bool) {
+	if key == keyCtrlR {
+		return c.reverseSearch(line)
+	}
+	return c.autocomplete(line, pos, key)
+}
+
+// reverseSearch replaces line with the most recent entry in the history that contains it.
+// Searching again, without changing the line, replaces it with the next most recent match.
+func (c *cli) reverseSearch(line string) (string, int, bool) {
+	text, before := line, len(c.history.entries)
+	if c.search.match != "" && line == c.search.match {
+		text, before = c.search.text, c.search.index
+	}
+
+	for {
+		index, ok := c.history.search(text, before)
+		if !ok {
+			return "", 0, false
+		}
+
+		// skip duplicates of what's already shown
+		if match := historyLine(c.history.entries[index]); match != line {
+			c.search = historySearch{text: text, index: index, match: match}
+			return match, len(match), true
+		}
+		before = index
+	}
+}
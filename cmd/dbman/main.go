@@ -1,12 +1,15 @@
 package main
 
 import (
-	"database/sql"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"dabbertorres.dev/dbman"
 	"golang.org/x/term"
@@ -19,10 +22,18 @@ func main() {
 		configFile  string
 		list        bool
 		listDrivers bool
+		command     string
+		file        string
+		format      string
+		maxRows     int
 	)
 	flag.StringVar(&configFile, "cfg", dbman.DefaultConfigFile, "specify a config file to use")
 	flag.BoolVar(&list, "list", false, "list available connections")
-	flag.BoolVar(&listDrivers, "list-drivers", false, "list available SQL drivers")
+	flag.BoolVar(&listDrivers, "list-drivers", false, "list available database drivers")
+	flag.StringVar(&command, "c", "", "run the given SQL and exit, rather than starting an interactive session")
+	flag.StringVar(&file, "f", "", "run the SQL in the given file (- for stdin) and exit, rather than starting an interactive session")
+	flag.StringVar(&format, "format", "table", "format to print results in when running non-interactively, one of: "+strings.Join(dbman.Formats(), ", "))
+	flag.IntVar(&maxRows, "max-rows", 0, "maximum number of rows to print per statement when running non-interactively, or 0 for no limit")
 	flag.Parse()
 
 	var cfg dbman.Config
@@ -32,12 +43,10 @@ func main() {
 
 	switch {
 	case list:
-		for k := range cfg.Connections {
-			fmt.Println(k)
-		}
+		listConnections(os.Stdout, &cfg)
 
 	case listDrivers:
-		for _, v := range sql.Drivers() {
+		for _, v := range dbman.Backends() {
 			fmt.Println(v)
 		}
 
@@ -47,8 +56,22 @@ func main() {
 			log.Fatalf("'%s' is not a configured connection", connName)
 		}
 
-		if !term.IsTerminal(0) {
-			log.Fatal("an active terminal is required")
+		script, batch, err := batchScript(command, file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if batch {
+			if _, ok := dbman.LookupFormat(format); !ok {
+				log.Fatalf("unknown format '%s', must be one of: %s", format, strings.Join(dbman.Formats(), ", "))
+			}
+			if maxRows < 0 {
+				log.Fatal("-max-rows must be greater than or equal to 0")
+			}
+			cfg.MaxRows = maxRows
+			if err := runBatch(dbman.New(&cfg), connName, script, format, &cfg.Display); err != nil {
+				log.Fatal(err)
+			}
+			return
 		}
 
 		prevState, err := term.MakeRaw(0)
@@ -57,16 +80,10 @@ func main() {
 		}
 		defer term.Restore(0, prevState)
 
-		terminal := term.NewTerminal(makeReadWriter(os.Stdin, os.Stdout), "> ")
-		terminal.AutoCompleteCallback = autocomplete
-
-		// just in case it is still set when we exit
-		defer terminal.SetBracketedPasteMode(false)
-
 		os.Stdin.Sync()
 
 		db := dbman.New(&cfg)
-		newCLI(terminal, db).run(connName)
+		newCLI(makeReadWriter(os.Stdin, os.Stdout), db, cfg.Display, 0, prevState).run(connName)
 	}
 }
 
@@ -78,3 +95,25 @@ type combinedReaderWriter struct {
 func makeReadWriter(r io.Reader, w io.Writer) *combinedReaderWriter {
 	return &combinedReaderWriter{Reader: r, Writer: w}
 }
+
+// listConnections prints each connection, with its settings as resolved
+// (e.g. those inherited from a template), other than secrets.
+func listConnections(w io.Writer, cfg *dbman.Config) {
+	names := make([]string, 0, len(cfg.Connections))
+	for k := range cfg.Connections {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDRIVER\tHOST\tPORT\tDATABASE\tUSERNAME\tTUNNEL\tEXTENDS")
+	for _, name := range names {
+		conn := cfg.Connections[name]
+		port := ""
+		if conn.Port != 0 {
+			port = strconv.Itoa(conn.Port)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, conn.Driver, conn.Host, port, conn.Database, conn.Username, conn.Tunnel, conn.Extends)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const defaultPager = "less -S"
+
+// errPagerNotStarted is returned if the pager couldn't be run at all, in which
+// case the text should be written some other way.
+var errPagerNotStarted = errors.New("failed to start pager")
+
+// errPagerClosed is returned when writing to a pager that has already exited,
+// e.g. because it was quit before reading everything.
+var errPagerClosed = errors.New("pager closed")
+
+// pagerCommand returns the command in $PAGER, or less -S if it isn't set.
+func pagerCommand() []string {
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = strings.Fields(defaultPager)
+	}
+	return args
+}
+
+// pager is a running pager, showing what's written to it.
+type pager struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	restore func()
+}
+
+// startPager runs the pager, which has the terminal (fd) until it's closed.
+func startPager(fd int, cookedState *term.State) (*pager, error) {
+	args := pagerCommand()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPagerNotStarted, err)
+	}
+
+	restore := cookedMode(fd, cookedState)
+	if err := cmd.Start(); err != nil {
+		restore()
+		return nil, fmt.Errorf("%w: %v", errPagerNotStarted, err)
+	}
+	return &pager{cmd: cmd, stdin: stdin, restore: restore}, nil
+}
+
+func (p *pager) Write(buf []byte) (int, error) {
+	n, err := p.stdin.Write(buf)
+	if err != nil {
+		return n, errPagerClosed
+	}
+	return n, nil
+}
+
+// Close waits for the pager to exit.
+func (p *pager) Close() error {
+	defer p.restore()
+
+	p.stdin.Close()
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("pager: %w", err)
+	}
+	return nil
+}
+
+// pagedWriter writes to the terminal, or the pager, depending on mode (see \pager).
+// In auto mode, output is held until it's taller than the terminal, when the pager
+// is started with it, or until it's closed, when it's written to the terminal.
+type pagedWriter struct {
+	terminal    io.Writer
+	mode        string
+	height      int // of the terminal
+	fd          int
+	cookedState *term.State
+
+	pending bytes.Buffer
+	lines   int
+	pager   *pager
+	direct  bool // writing straight to the terminal
+}
+
+func (w *pagedWriter) Write(buf []byte) (int, error) {
+	switch {
+	case w.pager != nil:
+		return w.pager.Write(buf)
+	case w.direct || w.mode == "off":
+		return w.terminal.Write(buf)
+	}
+
+	w.pending.Write(buf)
+	w.lines += bytes.Count(buf, []byte{'\n'})
+	// leave room for the summary, and the prompt
+	if w.mode == "on" || w.lines+2 > w.height {
+		if err := w.startPager(); err != nil {
+			return 0, err
+		}
+	}
+	return len(buf), nil
+}
+
+// startPager starts the pager with what's been written so far, or if that fails,
+// writes it to the terminal instead, as well as anything written afterwards.
+func (w *pagedWriter) startPager() error {
+	p, err := startPager(w.fd, w.cookedState)
+	if err != nil {
+		fmt.Fprintln(w.terminal, err)
+		w.direct = true
+		_, err := w.pending.WriteTo(w.terminal)
+		return err
+	}
+	w.pager = p
+	_, err = w.pending.WriteTo(p)
+	return err
+}
+
+// Close writes anything still held to the terminal, or waits for the pager to exit.
+func (w *pagedWriter) Close() error {
+	if w.pager != nil {
+		return w.pager.Close()
+	}
+	_, err := w.pending.WriteTo(w.terminal)
+	return err
+}
+
+// cookedMode returns the terminal (fd) to cookedState, until restore is called,
+// so that another program (e.g. the pager) can use it.
+// Ctrl-C is delivered to that program, which decides what to do with it.
+func cookedMode(fd int, cookedState *term.State) (restore func()) {
+	var state *term.State
+	if cookedState != nil {
+		if s, err := term.GetState(fd); err == nil {
+			if err := term.Restore(fd, cookedState); err == nil {
+				state = s
+			}
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+
+	return func() {
+		signal.Stop(sigs)
+		if state != nil {
+			term.Restore(fd, state)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package dbman
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,19 +20,28 @@ var DefaultConfigFile = func() string {
 
 type Config struct {
 	Connections map[string]Connection `json:"connections"`
+	Templates   map[string]Connection `json:"templates,omitempty"` // optional, partial connections, only for connections to extend
 	Tunnels     map[string]SSHTunnel  `json:"tunnels"`
+	MaxRows     int                   `json:"max_rows,omitempty"` // optional, maximum number of rows returned by a query, 0 for no limit
+	Display     Display               `json:"display"`            // optional, how values are shown
 }
 
 type Connection struct {
+	Extends           string            `json:"extends,omitempty"` // optional, a connection, or template, whose settings are used for any not set here
+	Service           string            `json:"service,omitempty"` // optional, a service in libpq's pg_service.conf, for any of host, port, database, username, and sslmode not set here
+	DSN               string            `json:"dsn,omitempty"`     // optional, a libpq connection string, or postgres:// URL, instead of (or under) the fields below
 	Host              string            `json:"host,omitempty"`
 	Port              int               `json:"port,omitempty"`
 	Database          string            `json:"database,omitempty"`
 	Username          string            `json:"username,omitempty"`
-	Password          string            `json:"password,omitempty"` // optional, prompted for if empty
+	Password          Secret            `json:"password,omitempty"` // optional, prompted for if empty
 	Driver            string            `json:"driver,omitempty"`
 	DriverOpts        map[string]string `json:"driver_opts,omitempty"`
 	Tunnel            string            `json:"tunnel,omitempty"`              // optional
 	ConnectTimeoutSec int               `json:"connect_timeout_sec,omitempty"` // optional
+	QueryTimeoutSec   int               `json:"query_timeout_sec,omitempty"`   // optional
+	ReadOnly          *bool             `json:"read_only,omitempty"`           // optional, refuse to run statements that write
+	ConfirmWrites     *bool             `json:"confirm_writes,omitempty"`      // optional, prompt before running statements that write
 	MaxOpenConns      int               `json:"max_open_conns,omitempty"`
 }
 
@@ -42,9 +50,9 @@ type SSHTunnel struct {
 	Port                   int        `json:"port,omitempty"`
 	User                   string     `json:"user,omitempty"`
 	AuthMethod             AuthMethod `json:"auth_method,omitempty"`
-	Password               string     `json:"password,omitempty"`               // only used if auth_method is 'password'; optional, prompted for if empty
+	Password               Secret     `json:"password,omitempty"`               // only used if auth_method is 'password'; optional, prompted for if empty
 	PrivateKeyFile         string     `json:"private_key_file,omitempty"`       // only used if auth_method is 'public_key'
-	PrivateKeyPassphrase   string     `json:"private_key_passphrase,omitempty"` // only used if auth_method is 'public_key' and private key is encrypted
+	PrivateKeyPassphrase   Secret     `json:"private_key_passphrase,omitempty"` // only used if auth_method is 'public_key' and private key is encrypted
 	ConnectTimeoutSec      int        `json:"connect_timeout_sec,omitempty"`    // optional
 	DisableVerifyKnownHost bool       `json:"disable_verify_known_host,omitempty"`
 	HostPublicKeyFile      string     `json:"host_public_key_file,omitempty"` // optional
@@ -58,52 +66,35 @@ const (
 	AgentAuth     AuthMethod = "agent"
 )
 
+// LoadConfig reads the config file at filePath into cfg.
+// The file's format (json, toml, or yaml) is chosen by its extension. It may
+// include other config files, whose settings it overrides, and refer to
+// environment variables in any string, as ${NAME}.
 func LoadConfig(filePath string, isDefault bool, cfg *Config) error {
-	f, err := os.Open(filePath)
+	if isDefault {
+		filePath = findDefaultConfigFile(filePath)
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return createDefaultConfig(filePath, cfg)
+		}
+	}
+
+	settings, err := readConfigFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			if isDefault {
-				dir := filepath.Dir(filePath)
-				if err := os.MkdirAll(dir, 0755); err != nil {
-					return fmt.Errorf("failed to create default config directory: %w", err)
-				}
-
-				f, err = os.Create(filePath)
-				if err != nil {
-					return fmt.Errorf("failed to create default config file: %w", err)
-				}
-				defer f.Close()
-				cfg.Connections = map[string]Connection{
-					// example
-					"localdb": {
-						Host:     "localhost",
-						Port:     5432,
-						Database: "postgres",
-						Username: "postgres",
-						Password: "postgres",
-						Driver:   "postgres",
-						DriverOpts: map[string]string{
-							"sslmode": "ignore",
-						},
-						ConnectTimeoutSec: 30,
-						MaxOpenConns:      4,
-					},
-				}
-				cfg.Tunnels = make(map[string]SSHTunnel)
-				json.NewEncoder(f).Encode(cfg)
-
-				return fmt.Errorf("default config file could not be found at '%s'; an empty config has been created with an example", "~/.config/dbman/config.json")
-			}
-
 			return fmt.Errorf("%s could not be found: %v", filePath, err)
 		}
 
-		return fmt.Errorf("could not open %s: %v", filePath, err)
+		return fmt.Errorf("could not read config: %w", err)
+	}
+
+	if err := decodeConfig(settings, cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
-	defer f.Close()
 
-	if err := json.NewDecoder(f).Decode(cfg); err != nil {
-		return fmt.Errorf("invalid config json: %w", err)
+	cfg.applyExtends()
+
+	if err := cfg.applyServices(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -116,6 +107,60 @@ func LoadConfig(filePath string, isDefault bool, cfg *Config) error {
 	return nil
 }
 
+// createDefaultConfig creates the default config file at filePath, with an example
+// connection. The error returned asks for it to be filled out.
+func createDefaultConfig(filePath string, cfg *Config) error {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create default config directory: %w", err)
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create default config file: %w", err)
+	}
+	defer f.Close()
+	cfg.Connections = map[string]Connection{
+		// example
+		"localdb": {
+			Host:     "localhost",
+			Port:     5432,
+			Database: "postgres",
+			Username: "postgres",
+			Password: Secret{Value: "postgres"},
+			Driver:   "postgres",
+			DriverOpts: map[string]string{
+				"sslmode": "ignore",
+			},
+			ConnectTimeoutSec: 30,
+			MaxOpenConns:      4,
+		},
+	}
+	cfg.Tunnels = make(map[string]SSHTunnel)
+	cfg.MaxRows = 1000
+	json.NewEncoder(f).Encode(cfg)
+
+	return fmt.Errorf("default config file could not be found at '%s'; an empty config has been created with an example", "~/.config/dbman/config.json")
+}
+
+// applyServices fills in the settings of connections that have a service.
+func (c *Config) applyServices() error {
+	var errs errorList
+
+	for k, v := range c.Connections {
+		if err := v.applyService(); err != nil {
+			errs = append(errs, errors.New(k+".service: "+err.Error()))
+			continue
+		}
+		c.Connections[k] = v
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
 func (c *Config) validate() error {
 	var errs errorList
 
@@ -133,12 +178,24 @@ func (c *Config) validate() error {
 		}
 	}
 
+	if err := c.validateExtends(); err != nil {
+		errs = append(errs, err)
+	}
+
 	for k, v := range c.Tunnels {
 		if err := v.validate(k); err != nil {
 			errs = append(errs, err)
 		}
 	}
 
+	if c.MaxRows < 0 {
+		errs = append(errs, errors.New("max_rows: must be greater than or equal to 0"))
+	}
+
+	if err := c.Display.validate("display"); err != nil {
+		errs = append(errs, err)
+	}
+
 	if len(errs) != 0 {
 		return errs
 	}
@@ -148,6 +205,37 @@ func (c *Config) validate() error {
 func (c *Connection) validate(prefix string) error {
 	var errs errorList
 
+	if c.Driver == "" {
+		errs = append(errs, errors.New(prefix+".driver: required"))
+	} else if backend, ok := lookupBackend(c.Driver); !ok {
+		errs = append(errs, errors.New(prefix+".driver: not a supported driver"))
+	} else if backend.Validate != nil {
+		if err := backend.Validate(prefix, c); err != nil {
+			errs = append(errs, err)
+		}
+	} else if err := c.validateNetwork(prefix); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Password.validate(prefix + ".password"); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ConnectTimeoutSec < 0 {
+		errs = append(errs, errors.New(prefix+".connect_timeout: must be greater than or equal to 0"))
+	}
+	if c.QueryTimeoutSec < 0 {
+		errs = append(errs, errors.New(prefix+".query_timeout: must be greater than or equal to 0"))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateNetwork is the default validation for backends connecting to a database server.
+func (c *Connection) validateNetwork(prefix string) error {
+	var errs errorList
+
 	if c.Host == "" {
 		errs = append(errs, errors.New(prefix+".host: required"))
 	}
@@ -160,14 +248,6 @@ func (c *Connection) validate(prefix string) error {
 	if c.Username == "" {
 		errs = append(errs, errors.New(prefix+".username: required"))
 	}
-	if c.Driver == "" {
-		errs = append(errs, errors.New(prefix+".driver: required"))
-	} else if !stringsContains(sql.Drivers(), c.Driver) {
-		errs = append(errs, errors.New(prefix+".driver: not a supported driver"))
-	}
-	if c.ConnectTimeoutSec < 0 {
-		errs = append(errs, errors.New(prefix+".connect_timeout: must be greater than or equal to 0"))
-	}
 
 	if len(errs) != 0 {
 		return errs
@@ -190,6 +270,12 @@ func (s *SSHTunnel) validate(prefix string) error {
 	if err := s.AuthMethod.validate(); err != nil {
 		errs = append(errs, errors.New(prefix+".auth_method: "+err.Error()))
 	}
+	if err := s.Password.validate(prefix + ".password"); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.PrivateKeyPassphrase.validate(prefix + ".private_key_passphrase"); err != nil {
+		errs = append(errs, err)
+	}
 	if s.ConnectTimeoutSec < 0 {
 		errs = append(errs, errors.New(prefix+".connect_timeout: must be greater than or equal to 0"))
 	}
@@ -245,3 +331,9 @@ func (e errorList) Error() string {
 
 	return sb.String()
 }
+
+// isTrue reports if b is set, and true, e.g. for Connection.ReadOnly, which is a
+// pointer so that a connection can turn off what it extends.
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
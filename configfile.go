@@ -0,0 +1,216 @@
+package dbman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configExtensions are the extensions of the config file formats, other than json.
+// A default config file may use any of them, e.g. ~/.config/dbman/config.toml.
+var configExtensions = []string{".toml", ".yaml", ".yml"}
+
+// readConfigFile reads the config file at path, and the files that it includes
+// (whose settings it overrides), with ${ENV} references expanded.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	return readConfigFileFrom(path, nil)
+}
+
+// readConfigFileFrom reads path, which was included by each of chain, in order.
+func readConfigFileFrom(path string, chain []string) (map[string]interface{}, error) {
+	for _, parent := range chain {
+		if parent == path {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), path)
+		}
+	}
+	chain = append(chain, path)
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := decodeConfigFile(path, buf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := expandConfigEnv(settings); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	includes, err := configIncludes(settings["include"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: include: %w", path, err)
+	}
+	delete(settings, "include")
+
+	merged := make(map[string]interface{})
+	for _, include := range includes {
+		include, err := expandHome(include)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		included, err := readConfigFileFrom(include, chain)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", include, err)
+		}
+		mergeConfig(merged, included)
+	}
+	mergeConfig(merged, settings)
+	return merged, nil
+}
+
+// decodeConfigFile decodes buf by path's extension; anything other than toml, or yaml, is json.
+func decodeConfigFile(path string, buf []byte) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		if _, err := toml.Decode(string(buf), &settings); err != nil {
+			return nil, err
+		}
+
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(buf, &settings); err != nil {
+			return nil, err
+		}
+
+	default:
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.UseNumber()
+		if err := dec.Decode(&settings); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+func configIncludes(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+
+	case string:
+		return []string{v}, nil
+
+	case []interface{}:
+		includes := make([]string, len(v))
+		for i, include := range v {
+			s, ok := include.(string)
+			if !ok {
+				return nil, errors.New("must be a file name, or a list of file names")
+			}
+			includes[i] = s
+		}
+		return includes, nil
+
+	default:
+		return nil, errors.New("must be a file name, or a list of file names")
+	}
+}
+
+// mergeConfig merges src into dst. Tables (e.g. connections) are merged, and
+// anything else in src replaces what's in dst.
+func mergeConfig(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcTable, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+
+		dstTable, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dstTable = make(map[string]interface{})
+			dst[k] = dstTable
+		}
+		mergeConfig(dstTable, srcTable)
+	}
+}
+
+// configEnvRef matches ${NAME}, or an escaped $${NAME}.
+var configEnvRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandConfigEnv replaces ${NAME} in every string in settings with the value of
+// the environment variable NAME, which must be set. $${NAME} is left as ${NAME}.
+func expandConfigEnv(settings map[string]interface{}) error {
+	var errs errorList
+
+	var expand func(v interface{}) interface{}
+	expand = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			return configEnvRef.ReplaceAllStringFunc(v, func(ref string) string {
+				if strings.HasPrefix(ref, "$$") {
+					return ref[1:]
+				}
+				name := ref[2 : len(ref)-1]
+				value, ok := os.LookupEnv(name)
+				if !ok {
+					errs = append(errs, fmt.Errorf("environment variable %s is not set", name))
+				}
+				return value
+			})
+
+		case map[string]interface{}:
+			for k, elem := range v {
+				v[k] = expand(elem)
+			}
+			return v
+
+		case []interface{}:
+			for i, elem := range v {
+				v[i] = expand(elem)
+			}
+			return v
+
+		default:
+			return v
+		}
+	}
+	expand(settings)
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// decodeConfig decodes settings, as read by readConfigFile, into cfg.
+// Every format is decoded like json, so that the same field names are used.
+func decodeConfig(settings map[string]interface{}, cfg *Config) error {
+	buf, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, cfg)
+}
+
+// findDefaultConfigFile returns path, or if it doesn't exist, the first of the
+// same file with another format's extension that does.
+func findDefaultConfigFile(path string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range configExtensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return path
+}
@@ -0,0 +1,181 @@
+package dbman
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbman-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	writeFile("infra/team.toml", `
+include = "regions.yaml"
+max_rows = 500
+
+[connections.prod]
+host = "prod.example.com"
+port = 5432
+database = "app"
+driver = "postgres"
+read_only = true
+
+[connections.prod.driver_opts]
+sslmode = "verify-full"
+application_name = "${DBMAN_TEST_APP}"
+
+[tunnels.bastion]
+host = "bastion.example.com"
+port = 22
+user = "${DBMAN_TEST_USER}"
+auth_method = "agent"
+`)
+	writeFile("infra/regions.yaml", `
+connections:
+  eu:
+    host: eu.example.com
+    port: 5432
+    database: app
+    username: reporting
+    driver: postgres
+    tunnel: bastion
+  prod:
+    host: overridden-by-team.example.com
+`)
+	personal := writeFile("config.json", `{
+		"include": ["infra/team.toml"],
+		"connections": {
+			"prod": {
+				"username": "${DBMAN_TEST_USER}",
+				"password": {"from": "env", "name": "DBMAN_TEST_PASSWORD"},
+				"driver_opts": {"sslmode": "require"}
+			},
+			"local": {
+				"driver": "sqlite",
+				"database": "$${HOME}/test.db"
+			}
+		},
+		"max_rows": 1000
+	}`)
+
+	defer setenv(t, "DBMAN_TEST_USER", "me")()
+	defer setenv(t, "DBMAN_TEST_APP", "dbman")()
+
+	var cfg Config
+	if err := LoadConfig(personal, false, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := Config{
+		Connections: map[string]Connection{
+			"prod": {
+				Host:       "prod.example.com",
+				Port:       5432,
+				Database:   "app",
+				Username:   "me",
+				Password:   Secret{From: "env", Name: "DBMAN_TEST_PASSWORD"},
+				Driver:     "postgres",
+				DriverOpts: map[string]string{"sslmode": "require", "application_name": "dbman"},
+				ReadOnly:   boolPtr(true),
+			},
+			"eu": {
+				Host:     "eu.example.com",
+				Port:     5432,
+				Database: "app",
+				Username: "reporting",
+				Driver:   "postgres",
+				Tunnel:   "bastion",
+			},
+			"local": {
+				Database: "${HOME}/test.db",
+				Driver:   "sqlite",
+			},
+		},
+		Tunnels: map[string]SSHTunnel{
+			"bastion": {Host: "bastion.example.com", Port: 22, User: "me", AuthMethod: AgentAuth},
+		},
+		MaxRows: 1000,
+	}
+	if diff := cmp.Diff(expect, cfg, cmpopts.IgnoreUnexported(Display{})); diff != "" {
+		t.Error(diff)
+	}
+
+	t.Run("validated after merging", func(t *testing.T) {
+		// prod's username is only in the personal file
+		var cfg Config
+		err := LoadConfig(filepath.Join(dir, "infra/team.toml"), false, &cfg)
+		if err == nil || !strings.Contains(err.Error(), "prod.username: required") {
+			t.Error("expected the team file alone to be missing prod's username, but got:", err)
+		}
+	})
+
+	t.Run("unset environment variable", func(t *testing.T) {
+		path := writeFile("unset.yaml", "connections:\n  x:\n    driver: sqlite\n    database: ${DBMAN_TEST_UNSET}\n")
+		var cfg Config
+		err := LoadConfig(path, false, &cfg)
+		if err == nil || !strings.Contains(err.Error(), "DBMAN_TEST_UNSET is not set") {
+			t.Error("expected an unset environment variable to be an error, but got:", err)
+		}
+	})
+
+	t.Run("include cycle", func(t *testing.T) {
+		writeFile("a.yaml", "include: b.toml\n")
+		writeFile("b.toml", `include = ["a.yaml"]`)
+		var cfg Config
+		err := LoadConfig(filepath.Join(dir, "a.yaml"), false, &cfg)
+		if err == nil || !strings.Contains(err.Error(), "include cycle") {
+			t.Error("expected an include cycle to be an error, but got:", err)
+		}
+	})
+
+	t.Run("missing include", func(t *testing.T) {
+		path := writeFile("missing.json", `{"include": "nope.json"}`)
+		var cfg Config
+		if err := LoadConfig(path, false, &cfg); err == nil {
+			t.Error("expected a missing include to be an error")
+		}
+	})
+
+	t.Run("missing include in the default config", func(t *testing.T) {
+		path := writeFile("default-include/config.json", `{"include": "nope.json"}`)
+		var cfg Config
+		err := LoadConfig(path, true, &cfg)
+		if err == nil || !strings.Contains(err.Error(), "include "+filepath.Join(dir, "default-include/nope.json")) {
+			t.Error("expected the missing include to be named in the error, but got:", err)
+		}
+		if buf, err := ioutil.ReadFile(path); err != nil || string(buf) != `{"include": "nope.json"}` {
+			t.Errorf("expected the default config to be left alone, but got: %s (%v)", buf, err)
+		}
+	})
+
+	t.Run("default config in another format", func(t *testing.T) {
+		writeFile("default/config.yml", "connections:\n  local:\n    driver: sqlite\n    database: ':memory:'\n")
+		var cfg Config
+		if err := LoadConfig(filepath.Join(dir, "default/config.json"), true, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cfg.Connections["local"]; !ok {
+			t.Error("expected config.yml to be loaded, but got:", cfg)
+		}
+	})
+}
@@ -3,39 +3,67 @@ package dbman
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"net"
 	"os"
-	"reflect"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 )
 
 type DBMan struct {
-	current        metaQuerier
-	cfg            *Config
-	activeQueriers map[string]metaQuerier
-	activeTunnels  map[string]*Tunnel
-	currentName    string
+	current       *session
+	cfg           *Config
+	sessions      map[string]*session
+	activeTunnels map[string]*Tunnel
+	currentName   string
+	notices       noticeLog
+}
+
+// session is an opened connection.
+type session struct {
+	MetaQuerier
+
+	// conn is pinned on first use, so that session state (e.g. transactions,
+	// SET variables) carries over between statements.
+	conn *sql.Conn
+	inTx bool
+
+	meta     func(db Querier) MetaQuerier // the backend's, to inspect the database with conn
+	openRows int32                        // Rows being read from conn, accessed atomically
+
+	readOnly      bool
+	confirmWrites bool
+	prompter      ssh.KeyboardInteractiveChallenge // to confirm writes
+	placeholder   func(n int) string
+	dialect       Dialect
+}
+
+func (s *session) Close() error {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return s.MetaQuerier.Close()
 }
 
 func New(cfg *Config) *DBMan {
 	return &DBMan{
-		cfg:            cfg,
-		current:        nil,
-		currentName:    "",
-		activeQueriers: make(map[string]metaQuerier),
-		activeTunnels:  make(map[string]*Tunnel),
+		cfg:           cfg,
+		current:       nil,
+		currentName:   "",
+		sessions:      make(map[string]*session),
+		activeTunnels: make(map[string]*Tunnel),
 	}
 }
 
 func (d *DBMan) Close() error {
-	for _, q := range d.activeQueriers {
-		q.Close()
+	for _, s := range d.sessions {
+		s.Close()
 	}
 
 	for _, t := range d.activeTunnels {
@@ -49,13 +77,21 @@ func (d *DBMan) CurrentName() string {
 	return d.currentName
 }
 
+// Dialect returns the active connection's Dialect, or standard SQL's if there isn't one.
+func (d *DBMan) Dialect() Dialect {
+	if d.current == nil {
+		return Dialect{}
+	}
+	return d.current.dialect
+}
+
 func (d *DBMan) ListConnections() (names []string, active []bool) {
 	names = make([]string, 0, len(d.cfg.Connections))
 	active = make([]bool, 0, len(d.cfg.Connections))
 
 	for k := range d.cfg.Connections {
 		names = append(names, k)
-		_, ok := d.activeQueriers[k]
+		_, ok := d.sessions[k]
 		active = append(active, ok)
 	}
 	return names, active
@@ -67,13 +103,15 @@ func (d *DBMan) SwitchConnection(connName string, prompter ssh.KeyboardInteracti
 		return fmt.Errorf("'%s' is not a configured connection", connName)
 	}
 
-	querier, ok := d.activeQueriers[connName]
-	if ok {
-		d.current = querier
+	if s, ok := d.sessions[connName]; ok {
+		d.current = s
 		d.currentName = connName
 		return nil
 	}
 
+	// secrets are looked up by where the database is, not the local end of a tunnel
+	target := conn
+
 	if conn.Tunnel != "" {
 		tunnel, ok := d.activeTunnels[conn.Tunnel]
 		if !ok {
@@ -94,32 +132,65 @@ func (d *DBMan) SwitchConnection(connName string, prompter ssh.KeyboardInteracti
 		conn.Port, _ = strconv.Atoi(localPort)
 	}
 
-	if conn.Password == "" {
-		// is it provided in an environment variable?
-		if pgpassword := os.Getenv("PGPASSWORD"); pgpassword != "" {
-			conn.Password = pgpassword
-		} else {
+	backend, ok := lookupBackend(conn.Driver)
+	if !ok {
+		return errors.New("unsupported database driver")
+	}
+
+	if !backend.NoPassword {
+		password, err := conn.Password.Resolve(&target)
+		if err != nil {
+			return fmt.Errorf("could not resolve database password: %w", err)
+		}
+
+		if password == "" && backend.DSNPassword != nil {
+			// is it in the connection's dsn?
+			found, ok, err := backend.DSNPassword(&target)
+			if err != nil {
+				return fmt.Errorf("invalid dsn: %w", err)
+			}
+			if ok {
+				password = found
+			}
+		}
+		if password == "" {
+			// is it provided in an environment variable?
+			password = os.Getenv(backend.PasswordEnv)
+		}
+		if password == "" && backend.LookupPassword != nil {
+			// or somewhere else the database's tools look, e.g. a password file?
+			found, ok, err := backend.LookupPassword(&target)
+			if err != nil {
+				return fmt.Errorf("could not look up database password: %w", err)
+			}
+			if ok {
+				password = found
+			}
+		}
+		if password == "" {
 			answers, err := prompter("", "", []string{"database password: "}, []bool{false})
 			if err != nil {
 				return err
 			}
-			conn.Password = answers[0]
+			password = answers[0]
 		}
+		conn.Password = Secret{Value: password}
 	}
 
-	switch conn.Driver {
-	case "postgres":
-		db, err := postgresOpen(&conn)
-		if err != nil {
-			return fmt.Errorf("failed to open database connection: %w", err)
-		}
-		db.SetMaxOpenConns(conn.MaxOpenConns)
-		db.SetConnMaxIdleTime(1 * time.Hour)
-		querier = dbMeta{db}
-
-	default:
-		return errors.New("unsupported database driver")
+	connector, err := backend.Connector(&conn)
+	if err != nil {
+		return fmt.Errorf("failed to open database connection: %w", err)
+	}
+	if backend.Notices != nil {
+		connector = backend.Notices(connector, d.notices.add)
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(conn.MaxOpenConns)
+	db.SetConnMaxIdleTime(1 * time.Hour)
+	if backend.Configure != nil {
+		backend.Configure(db, &conn)
 	}
+	querier := backend.Meta(db)
 
 	ctx := context.Background()
 	if conn.ConnectTimeoutSec != 0 {
@@ -131,35 +202,122 @@ func (d *DBMan) SwitchConnection(connName string, prompter ssh.KeyboardInteracti
 		return fmt.Errorf("failed to connect to database instance: %w", err)
 	}
 
-	d.activeQueriers[connName] = querier
-	d.current = querier
+	s := &session{
+		MetaQuerier:   querier,
+		readOnly:      isTrue(conn.ReadOnly),
+		confirmWrites: isTrue(conn.ConfirmWrites),
+		prompter:      prompter,
+		placeholder:   backend.Placeholder,
+		dialect:       backend.Dialect,
+		meta:          backend.Meta,
+	}
+	d.sessions[connName] = s
+	d.current = s
 	d.currentName = connName
 	return nil
 }
 
-func (d *DBMan) ListTables(schema string) ([]string, error) {
+// withQueryTimeout applies the active connection's query timeout (if any) to ctx.
+func (d *DBMan) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.cfg != nil {
+		if conn, ok := d.cfg.Connections[d.currentName]; ok && conn.QueryTimeoutSec != 0 {
+			return context.WithTimeout(ctx, time.Duration(conn.QueryTimeoutSec)*time.Second)
+		}
+	}
+	return context.WithCancel(ctx)
+}
+
+// pinnedConn returns the current session's connection, pinning one if necessary.
+func (d *DBMan) pinnedConn(ctx context.Context) (*sql.Conn, error) {
+	if d.current.conn == nil {
+		conn, err := d.current.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.current.conn = conn
+	}
+	return d.current.conn, nil
+}
+
+// metaQuerier returns the current session's MetaQuerier, and the context to use
+// it with, which must be cancelled once it's done with.
+//
+// In a transaction, its pinned connection is used, so that what the transaction
+// has changed is seen (e.g. tables it created), as it is if the pool has no other
+// connection to use (i.e. max_open_conns is 1). There's no deadline then, since
+// some drivers (e.g. lib/pq) close the connection when a query is cancelled,
+// rolling the transaction back. Otherwise, the pool is used, with ctx, and the
+// query timeout. It's also used while rows are still being read from the pinned connection.
+func (d *DBMan) metaQuerier(ctx context.Context) (MetaQuerier, context.Context, context.CancelFunc) {
+	s := d.current
+	pinned := s.conn != nil && s.meta != nil && atomic.LoadInt32(&s.openRows) == 0 &&
+		(s.inTx || s.Stats().MaxOpenConnections == 1)
+	if pinned {
+		return s.meta(pinnedQuerier{Querier: s.MetaQuerier, conn: s.conn}), context.Background(), func() {}
+	}
+
+	ctx, cancel := d.withQueryTimeout(ctx)
+	return s.MetaQuerier, ctx, cancel
+}
+
+// pinnedQuerier runs queries on a pinned connection, rather than the pool.
+type pinnedQuerier struct {
+	Querier
+	conn *sql.Conn
+}
+
+func (q pinnedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return q.conn.ExecContext(ctx, query, args...)
+}
+
+func (q pinnedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.conn.QueryContext(ctx, query, args...)
+}
+
+// dropBadConn unpins the current session's connection if err shows it can no longer be used.
+// Any open transaction is lost with it.
+func (d *DBMan) dropBadConn(err error) {
+	if d.current.conn != nil && (errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone)) {
+		d.current.conn.Close()
+		d.current.conn = nil
+		d.current.inTx = false
+	}
+}
+
+func (d *DBMan) ListTables(ctx context.Context, schema string) ([]string, error) {
 	if d.current == nil {
 		return nil, errors.New("an active connection is required")
 	}
 
+	meta, ctx, cancel := d.metaQuerier(ctx)
+	defer cancel()
+
 	if schema != "" {
-		return d.current.ListTablesInSchema(schema)
+		return meta.ListTablesInSchema(ctx, schema)
 	}
-	return d.current.ListTables()
+	return meta.ListTables(ctx)
 }
 
-func (d *DBMan) ListSchemas() ([]string, error) {
+func (d *DBMan) ListSchemas(ctx context.Context) ([]string, error) {
 	if d.current == nil {
 		return nil, errors.New("an active connection is required")
 	}
-	return d.current.ListSchemas()
+
+	meta, ctx, cancel := d.metaQuerier(ctx)
+	defer cancel()
+
+	return meta.ListSchemas(ctx)
 }
 
-func (d *DBMan) DescribeTable(name string) (*TableSchema, error) {
+func (d *DBMan) DescribeTable(ctx context.Context, name string) (*TableSchema, error) {
 	if d.current == nil {
 		return nil, errors.New("an active connection is required")
 	}
-	return d.current.DescribeTable(name)
+
+	meta, ctx, cancel := d.metaQuerier(ctx)
+	defer cancel()
+
+	return meta.DescribeTable(ctx, name)
 }
 
 func (d *DBMan) Stats() sql.DBStats {
@@ -170,94 +328,95 @@ func (d *DBMan) Stats() sql.DBStats {
 }
 
 type QueryResult struct {
-	Columns []string
-	Rows    [][]interface{}
+	Columns     []string // nil if the statement didn't return any rows
+	ColumnTypes []string // database type names, e.g. "VARCHAR", as reported by the driver
+	Rows        [][]interface{}
+	More        bool // more rows were available, but the configured maximum number of rows was reached
+
+	// only set if the statement didn't return any rows
+	RowsAffected int64  // -1 if unknown
+	Command      string // what the statement did, e.g. "UPDATE 42", or "CREATE TABLE"
 }
 
-// Query returns a QueryResult with the results of the provided script.
-// If no error occurred, and there were no results (e.g, an INSERT/CREATE),
-// a nil QueryResult is returned.
-func (d *DBMan) Query(script string) (*QueryResult, error) {
+// ColumnType returns the database type name of the ith column, or "" if it isn't known.
+func (r *QueryResult) ColumnType(i int) string {
+	if i < len(r.ColumnTypes) {
+		return r.ColumnTypes[i]
+	}
+	return ""
+}
+
+// Query returns a QueryResult with the results of the provided statement.
+// At most Config.MaxRows rows are returned.
+// If the statement didn't return any rows (e.g. an INSERT/CREATE), the result
+// has no Columns, and RowsAffected and Command describe what it did instead.
+// Only the first result set is returned, use QueryScript to run multiple statements.
+// args are passed to the driver for any placeholders in stmt.
+func (d *DBMan) Query(ctx context.Context, stmt string, args ...interface{}) (*QueryResult, error) {
 	if d.current == nil {
 		return nil, errors.New("an active connection is required")
 	}
 
-	rows, err := d.current.Query(script)
-	if err != nil {
-		return nil, err
+	result, rows := d.runStatement(ctx, stmt, args)
+	if result.Err != nil {
+		return nil, result.Err
 	}
-	defer rows.Close()
-
-	columns, err := rows.ColumnTypes()
-	if err != nil {
-		return nil, err
+	if rows != nil {
+		defer rows.Close()
+		if err := rows.ReadAll(&result.QueryResult); err != nil {
+			return nil, err
+		}
 	}
+	return &result.QueryResult, nil
+}
 
-	if len(columns) == 0 {
-		return nil, nil
+// QueryRows runs the provided script, returning Rows to stream the results with.
+// Rows stops after Config.MaxRows rows.
+// If no error occurred, and there were no results (e.g, an INSERT/CREATE),
+// nil Rows are returned.
+//
+// Canceling ctx interrupts the query. For postgres, this also cancels
+// the query server-side.
+func (d *DBMan) QueryRows(ctx context.Context, script string, args ...interface{}) (*Rows, error) {
+	if d.current == nil {
+		return nil, errors.New("an active connection is required")
 	}
 
-	var (
-		result = QueryResult{
-			Columns: make([]string, len(columns)),
-		}
-
-		scanners = make([]interface{}, len(columns))
-	)
-	for i, col := range columns {
-		result.Columns[i] = col.Name()
-		switch strings.ToUpper(col.DatabaseTypeName()) {
-		case "CHARACTER", "CHAR", "CHARACTER VARYING", "VARCHAR", "NVARCHAR", "TEXT":
-			scanners[i] = new(nullString)
-
-		case "BOOL", "BOOLEAN":
-			scanners[i] = new(nullBool)
-
-		case "BIGINT", "INT8", "BIGSERIAL", "SERIAL8", "INTERVAL":
-			scanners[i] = new(nullInt64)
-
-		case "INTEGER", "INT", "INT4", "SERIAL", "SERIAL4":
-			scanners[i] = new(nullInt32)
-
-		case "SMALLINT", "INT2", "SMALLSERIAL", "SERIAL2":
-			scanners[i] = new(nullInt16)
-
-		case "DOUBLE", "FLOAT8", "NUMERIC", "DECIMAL":
-			scanners[i] = new(nullFloat64)
-
-		case "REAL", "FLOAT4":
-			scanners[i] = new(nullFloat32)
-
-		case "TIMESTAMP", "TIMESTAMPTZ", "TIME", "TIMETZ", "DATE":
-			scanners[i] = new(nullTime)
-
-		case "UUID":
-			scanners[i] = new(uuidVal)
+	if err := d.checkWrite(script); err != nil {
+		return nil, err
+	}
+	return d.queryRows(ctx, script, args...)
+}
 
-		case "ARRAY":
-			scanners[i] = new([]interface{})
+// queryRows is QueryRows, without checking if script may be run.
+func (d *DBMan) queryRows(ctx context.Context, script string, args ...interface{}) (*Rows, error) {
+	// canceled when the Rows are closed
+	ctx, cancel := d.withQueryTimeout(ctx)
 
-		default:
-			scanners[i] = reflect.New(col.ScanType()).Interface()
-		}
+	conn, err := d.pinnedConn(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
-	for rows.Next() {
-		if err := rows.Scan(scanners...); err != nil {
-			return nil, err
-		}
-
-		data := make([]interface{}, len(scanners))
-		for i, val := range scanners {
-			if val == nil {
-				data[i] = nullValue{}
-			} else {
-				data[i] = reflect.Indirect(reflect.ValueOf(val)).Interface()
-			}
-		}
+	rows, err := conn.QueryContext(ctx, script, args...)
+	if err != nil {
+		d.dropBadConn(err)
+		cancel()
+		return nil, err
+	}
 
-		result.Rows = append(result.Rows, data)
+	var maxRows int
+	if d.cfg != nil {
+		maxRows = d.cfg.MaxRows
 	}
 
-	return &result, rows.Err()
+	// conn is busy until the rows are closed
+	s := d.current
+	atomic.AddInt32(&s.openRows, 1)
+	var once sync.Once
+	return newRows(rows, maxRows, func() {
+		cancel()
+		once.Do(func() { atomic.AddInt32(&s.openRows, -1) })
+	})
 }
@@ -1,11 +1,16 @@
 package dbman
 
 import (
+	"context"
 	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
 )
 
 func Test_DBMan_Query(t *testing.T) {
@@ -31,10 +36,10 @@ func Test_DBMan_Query(t *testing.T) {
 		RowsWillBeClosed()
 
 	dbman := DBMan{
-		current: dbMeta{db},
+		current: &session{MetaQuerier: dbMeta{db}},
 	}
 
-	result, err := dbman.Query("SELECT foo, bar, baz FROM xyzzy")
+	result, err := dbman.Query(context.Background(), "SELECT foo, bar, baz FROM xyzzy")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -62,3 +67,397 @@ func Test_DBMan_Query(t *testing.T) {
 		}
 	}
 }
+
+func Test_DBMan_Query_Types(t *testing.T) {
+	tests := []struct {
+		dbType   string
+		raw      driver.Value
+		wantText string
+		wantJSON string
+	}{
+		{dbType: "NUMERIC", raw: []byte("12345678901234567890.123456789"), wantText: "12345678901234567890.123456789", wantJSON: "12345678901234567890.123456789"},
+		{dbType: "NUMERIC", raw: []byte("NaN"), wantText: "NaN", wantJSON: `"NaN"`},
+		{dbType: "INTERVAL", raw: []byte("1 year 2 mons -3 days 04:05:06.5"), wantText: "1 year 2 mons -3 days 04:05:06.5", wantJSON: `"1 year 2 mons -3 days 04:05:06.5"`},
+		{dbType: "INTERVAL", raw: []byte("-1 days -00:00:01.25"), wantText: "-1 days -00:00:01.25", wantJSON: `"-1 days -00:00:01.25"`},
+		{dbType: "INTERVAL", raw: []byte("00:00:00"), wantText: "00:00:00", wantJSON: `"00:00:00"`},
+		{dbType: "INTERVAL", raw: []byte("P1Y2M"), wantText: "P1Y2M", wantJSON: `"P1Y2M"`},
+		{dbType: "_INT4", raw: []byte("{1,2,NULL}"), wantText: "{1,2,NULL}", wantJSON: "[1,2,null]"},
+		{dbType: "_INT4", raw: []byte("{{1,2},{3,4}}"), wantText: "{{1,2},{3,4}}", wantJSON: "[[1,2],[3,4]]"},
+		{dbType: "_INT4", raw: []byte("[0:1]={1,2}"), wantText: "{1,2}", wantJSON: "[1,2]"},
+		{
+			dbType:   "_TEXT",
+			raw:      []byte(`{"a b","with \"quote\"",NULL,"NULL",plain}`),
+			wantText: `{"a b","with \"quote\"",NULL,"NULL",plain}`,
+			wantJSON: `["a b","with \"quote\"",null,"NULL","plain"]`,
+		},
+		{dbType: "_BOOL", raw: []byte("{t,f}"), wantText: "{true,false}", wantJSON: "[true,false]"},
+		{dbType: "INT4RANGE", raw: []byte("[1,5)"), wantText: "[1,5)", wantJSON: `"[1,5)"`},
+		{dbType: "INT4RANGE", raw: []byte("(,5]"), wantText: "(,5]", wantJSON: `"(,5]"`},
+		{dbType: "INT4RANGE", raw: []byte("empty"), wantText: "empty", wantJSON: `"empty"`},
+		{
+			dbType:   "TSTZRANGE",
+			raw:      []byte(`["2021-01-01 00:00:00+00","2021-02-01 00:00:00+00")`),
+			wantText: `["2021-01-01 00:00:00+00","2021-02-01 00:00:00+00")`,
+			wantJSON: `"[\"2021-01-01 00:00:00+00\",\"2021-02-01 00:00:00+00\")"`,
+		},
+		{dbType: "TIME", raw: []byte("838:59:59"), wantText: "838:59:59", wantJSON: `"838:59:59"`},
+		{dbType: "INET", raw: []byte("192.168.0.1"), wantText: "192.168.0.1", wantJSON: `"192.168.0.1"`},
+		{dbType: "CIDR", raw: []byte("2001:db8::/32"), wantText: "2001:db8::/32", wantJSON: `"2001:db8::/32"`},
+		{dbType: "JSONB", raw: []byte(`{"a":1,"b":[true,"x, y:z"]}`), wantText: `{"a": 1, "b": [true, "x, y:z"]}`, wantJSON: `{"a":1,"b":[true,"x, y:z"]}`},
+		{dbType: "JSON", raw: []byte(`not json`), wantText: `not json`, wantJSON: `"not json"`},
+		{dbType: "BYTEA", raw: []byte{0xde, 0xad, 0xbe, 0xef}, wantText: `\xdeadbeef`, wantJSON: `"\\xdeadbeef"`},
+		{dbType: "BYTEA", raw: nil, wantText: "NULL", wantJSON: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType+" "+tt.wantText, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rows := sqlmock.NewRowsWithColumnDefinition(sqlmock.NewColumn("v").OfType(tt.dbType, []byte(nil))).
+				AddRow(tt.raw)
+			mock.ExpectQuery("SELECT v").WillReturnRows(rows)
+
+			dbman := DBMan{
+				current: &session{MetaQuerier: dbMeta{db}},
+			}
+
+			result, err := dbman.Query(context.Background(), "SELECT v")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Rows) != 1 {
+				t.Fatalf("expected 1 row, but got %d", len(result.Rows))
+			}
+
+			val := result.Rows[0][0]
+			if text := fmt.Sprint(val); text != tt.wantText {
+				t.Errorf("expected %s, but got %s (%T)", tt.wantText, text, val)
+			}
+
+			buf, err := json.Marshal(jsonValue(plainValue(val)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(buf) != tt.wantJSON {
+				t.Errorf("expected JSON %s, but got %s", tt.wantJSON, buf)
+			}
+		})
+	}
+}
+
+func Test_DBMan_QueryRows_MaxRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < 2; n++ {
+		rows := sqlmock.NewRows([]string{"id"})
+		for i := int64(0); i < 5; i++ {
+			rows.AddRow(i)
+		}
+		mock.ExpectQuery("SELECT id FROM events").
+			WillReturnRows(rows).
+			RowsWillBeClosed()
+	}
+
+	dbman := DBMan{
+		cfg:     &Config{MaxRows: 3},
+		current: &session{MetaQuerier: dbMeta{db}},
+	}
+
+	result, err := dbman.Query(context.Background(), "SELECT id FROM events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Rows) != 3 {
+		t.Errorf("expected %d rows, but was %d", 3, len(result.Rows))
+	}
+	if !result.More {
+		t.Error("expected more rows to be available")
+	}
+
+	// read in batches, which keep calling Next after the cap
+	rows, err := dbman.QueryRows(context.Background(), "SELECT id FROM events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for batch := rows.Batch(2); len(batch) != 0; batch = rows.Batch(2) {
+		count += len(batch)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || !rows.More() || rows.Err() != nil {
+		t.Errorf("expected 3 rows, with more available, but got %d rows, more: %v, err: %v", count, rows.More(), rows.Err())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_DBMan_QueryScript(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec("UPDATE users SET active = false WHERE id = 7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(int64(7), "bob")).
+		RowsWillBeClosed()
+	mock.ExpectExec("DELETE FROM nope").
+		WillReturnError(errors.New(`relation "nope" does not exist`))
+
+	dbman := DBMan{
+		current: &session{MetaQuerier: dbMeta{db}},
+	}
+
+	script := `UPDATE users SET active = false WHERE id = 7;
+               SELECT id, name FROM users;
+               DELETE FROM nope;
+               SELECT 'never run';`
+	results, err := collectScript(context.Background(), &dbman, script, nil)
+	if err == nil || err != results[len(results)-1].Err {
+		t.Fatal("expected the failing statement's error, but got:", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("expected %d results, but got %d", 3, len(results))
+	}
+
+	if results[0].Columns != nil || results[0].RowsAffected != 1 || results[0].Err != nil {
+		t.Errorf("unexpected UPDATE result: %+v", results[0])
+	}
+
+	if diff := cmp.Diff([]string{"id", "name"}, results[1].Columns); diff != "" {
+		t.Errorf("unexpected SELECT columns (-want +got):\n%s", diff)
+	}
+	if len(results[1].Rows) != 1 || results[1].RowsAffected != -1 || results[1].Err != nil {
+		t.Errorf("unexpected SELECT result: %+v", results[1])
+	}
+
+	if results[2].Err == nil {
+		t.Error("expected DELETE to fail")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_DBMan_Query_Exec(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec("UPDATE users SET active = false").
+		WillReturnResult(sqlmock.NewResult(0, 42))
+
+	dbman := DBMan{
+		current: &session{MetaQuerier: dbMeta{db}},
+	}
+
+	result, err := dbman.Query(context.Background(), "UPDATE users SET active = false")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := &QueryResult{RowsAffected: 42, Command: "UPDATE 42"}
+	if diff := cmp.Diff(expect, result); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_DBMan_Transaction(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE users SET active = false").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("ROLLBACK").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("START TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	dbman := DBMan{
+		current:  &session{MetaQuerier: dbMeta{db}},
+		sessions: map[string]*session{},
+	}
+	dbman.sessions["test"] = dbman.current
+
+	ctx := context.Background()
+	if err := dbman.Commit(ctx); err == nil {
+		t.Error("expected an error committing without a transaction")
+	}
+
+	if err := dbman.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !dbman.InTransaction() {
+		t.Error("expected a transaction to be open after Begin")
+	}
+	if err := dbman.Begin(ctx); err == nil {
+		t.Error("expected an error beginning a nested transaction")
+	}
+
+	if _, err := dbman.Query(ctx, "UPDATE users SET active = false"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"test"}, dbman.OpenTransactions()); diff != "" {
+		t.Errorf("unexpected open transactions (-want +got):\n%s", diff)
+	}
+
+	if err := dbman.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if dbman.InTransaction() {
+		t.Error("expected no transaction after Rollback")
+	}
+
+	// managed with statements, rather than Begin/Commit
+	if _, err := collectScript(ctx, &dbman, "START TRANSACTION;", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !dbman.InTransaction() {
+		t.Error("expected START TRANSACTION to open a transaction")
+	}
+	if _, err := dbman.Query(ctx, "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	if dbman.InTransaction() {
+		t.Error("expected COMMIT to close the transaction")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_session_trackTransaction(t *testing.T) {
+	tests := []struct {
+		stmt   string
+		inTx   bool
+		expect bool
+	}{
+		{stmt: "BEGIN", expect: true},
+		{stmt: "start transaction read only", expect: true},
+		{stmt: "COMMIT", inTx: true, expect: false},
+		{stmt: "END", inTx: true, expect: false},
+		{stmt: "end transaction", inTx: true, expect: false},
+		{stmt: "ENDPOINT", inTx: true, expect: true},
+		{stmt: "END IF", inTx: true, expect: true},
+		{stmt: "ABORT WORK", inTx: true, expect: false},
+		{stmt: "ROLLBACK TO SAVEPOINT a", inTx: true, expect: true},
+		{stmt: "ROLLBACK", inTx: true, expect: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			s := session{inTx: tt.inTx}
+			s.trackTransaction(tt.stmt)
+			if s.inTx != tt.expect {
+				t.Errorf("expected inTx to be %v, but was %v", tt.expect, s.inTx)
+			}
+		})
+	}
+}
+
+func Test_DBMan_checkWrite(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	t.Run("read only", func(t *testing.T) {
+		dbman := DBMan{
+			current: &session{MetaQuerier: dbMeta{db}, readOnly: true},
+		}
+
+		// never sent to the database
+		if _, err := dbman.Query(ctx, "DELETE FROM users"); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected ErrReadOnly, but got: %v", err)
+		}
+		if _, err := dbman.QueryRows(ctx, "drop table users"); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected ErrReadOnly, but got: %v", err)
+		}
+	})
+
+	t.Run("confirm writes", func(t *testing.T) {
+		var answer string
+		dbman := DBMan{
+			current: &session{
+				MetaQuerier:   dbMeta{db},
+				confirmWrites: true,
+				prompter: func(_, _ string, questions []string, _ []bool) ([]string, error) {
+					return []string{answer}, nil
+				},
+			},
+		}
+
+		answer = "n"
+		if _, err := dbman.Query(ctx, "DELETE FROM users"); err == nil {
+			t.Error("expected an error when the write isn't confirmed")
+		}
+
+		mock.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 2))
+		answer = "y"
+		if _, err := dbman.Query(ctx, "DELETE FROM users"); err != nil {
+			t.Error("expected confirmed write to run, but got:", err)
+		}
+	})
+
+	t.Run("confirmed once", func(t *testing.T) {
+		prompts := 0
+		dbman := DBMan{
+			current: &session{
+				MetaQuerier:   dbMeta{db},
+				confirmWrites: true,
+				prompter: func(_, _ string, questions []string, _ []bool) ([]string, error) {
+					prompts++
+					return []string{"y"}, nil
+				},
+			},
+		}
+
+		mock.ExpectQuery("DELETE FROM users RETURNING id").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+		if _, err := dbman.Query(ctx, "DELETE FROM users RETURNING id"); err != nil {
+			t.Fatal(err)
+		}
+		if prompts != 1 {
+			t.Errorf("expected to be prompted once, but was prompted %d times", prompts)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// collectScript runs script, and returns the results of its statements, with their rows.
+func collectScript(ctx context.Context, db *DBMan, script string, vars VarLookup) ([]StatementResult, error) {
+	var results []StatementResult
+	err := db.QueryScript(ctx, script, vars, func(result *StatementResult, rows *Rows) error {
+		if rows != nil {
+			if err := rows.ReadAll(&result.QueryResult); err != nil {
+				return err
+			}
+		}
+		results = append(results, *result)
+		return nil
+	})
+	return results, err
+}
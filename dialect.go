@@ -0,0 +1,325 @@
+package dbman
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect describes how a database quotes identifiers and literals, for
+// generating SQL from query results.
+// The zero value is standard SQL.
+type Dialect struct {
+	// IdentQuote quotes identifiers. If 0, '"' is used.
+	IdentQuote byte
+
+	// BackslashEscapes is set if backslashes in string literals are escape
+	// characters (e.g. mysql), so must be escaped themselves.
+	BackslashEscapes bool
+
+	// HashComments is set if # starts a comment, until the end of the line (e.g. mysql).
+	HashComments bool
+
+	// ByteaLiterals writes binary values as '\x...' strings (e.g. postgres),
+	// rather than X'...'.
+	ByteaLiterals bool
+
+	// NumericBools writes booleans as 1 and 0, rather than TRUE and FALSE.
+	NumericBools bool
+
+	// TimeLayout formats timestamps.
+	// If empty, "2006-01-02 15:04:05.999999999-07:00" is used.
+	TimeLayout string
+
+	// Copy is set if COPY ... FROM stdin is supported (i.e. postgres).
+	Copy bool
+}
+
+// WriteInserts writes an INSERT statement into table for each of result's rows.
+// table is written as is, so may be schema qualified, or quoted.
+func (d Dialect) WriteInserts(w io.Writer, table string, result *QueryResult) error {
+	prefix := "INSERT INTO " + table + " (" + d.identList(result.Columns) + ") VALUES ("
+
+	values := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, val := range row {
+			values[i] = d.Literal(val, result.ColumnType(i))
+		}
+		if _, err := io.WriteString(w, prefix+strings.Join(values, ", ")+");\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteUpdates writes an UPDATE statement of table for each of result's rows,
+// setting every column that isn't one of keys, for the row matching keys.
+func (d Dialect) WriteUpdates(w io.Writer, table string, keys []string, result *QueryResult) error {
+	if len(keys) == 0 {
+		return errors.New("at least one key column is required")
+	}
+
+	isKey := make([]bool, len(result.Columns))
+	for _, key := range keys {
+		found := false
+		for i, col := range result.Columns {
+			if col == key {
+				isKey[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("key column '%s' isn't in the result", key)
+		}
+	}
+
+	var sets, wheres []string
+	for _, row := range result.Rows {
+		sets, wheres = sets[:0], wheres[:0]
+		for i, val := range row {
+			col := d.QuoteIdent(result.Columns[i])
+			lit := d.Literal(val, result.ColumnType(i))
+			switch {
+			case !isKey[i]:
+				sets = append(sets, col+" = "+lit)
+			case lit == "NULL":
+				wheres = append(wheres, col+" IS NULL")
+			default:
+				wheres = append(wheres, col+" = "+lit)
+			}
+		}
+		if len(sets) == 0 {
+			return errors.New("there are no columns to update, besides the key columns")
+		}
+
+		stmt := "UPDATE " + table + " SET " + strings.Join(sets, ", ") + " WHERE " + strings.Join(wheres, " AND ") + ";\n"
+		if _, err := io.WriteString(w, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCopy writes a COPY table FROM stdin block with result's rows, in postgres'
+// text format. It fails if the dialect doesn't support COPY.
+func (d Dialect) WriteCopy(w io.Writer, table string, result *QueryResult) error {
+	cw, err := d.NewCopyWriter(w, table, result.Columns, result.ColumnTypes)
+	if err != nil {
+		return err
+	}
+	if err := cw.Write(result.Rows); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// CopyWriter writes a single COPY table FROM stdin block, a batch of rows at a
+// time, like a ResultWriter.
+type CopyWriter struct {
+	w       io.Writer
+	dialect Dialect
+	table   string
+	result  QueryResult // the columns, and the batch being written
+	count   int         // rows written so far
+	started bool
+}
+
+// NewCopyWriter returns a CopyWriter for rows with columns into table.
+// It fails if the dialect doesn't support COPY.
+func (d Dialect) NewCopyWriter(w io.Writer, table string, columns, columnTypes []string) (*CopyWriter, error) {
+	if !d.Copy {
+		return nil, errors.New("COPY isn't supported by this database")
+	}
+	return &CopyWriter{
+		w:       w,
+		dialect: d,
+		table:   table,
+		result:  QueryResult{Columns: columns, ColumnTypes: columnTypes},
+	}, nil
+}
+
+// Write writes a batch of rows, after the COPY statement, if it hasn't been written yet.
+func (cw *CopyWriter) Write(rows [][]interface{}) error {
+	var sb strings.Builder
+	if !cw.started {
+		sb.WriteString("COPY " + cw.table + " (" + cw.dialect.identList(cw.result.Columns) + ") FROM stdin;\n")
+		cw.started = true
+	}
+
+	fields := make([]string, len(cw.result.Columns))
+	for _, row := range rows {
+		for i, val := range row {
+			fields[i] = cw.dialect.copyValue(val, cw.result.ColumnType(i))
+		}
+		sb.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+
+	if _, err := io.WriteString(cw.w, sb.String()); err != nil {
+		return err
+	}
+	cw.count += len(rows)
+	return nil
+}
+
+// Count returns the number of rows written so far.
+func (cw *CopyWriter) Count() int {
+	return cw.count
+}
+
+// Close ends the block, after writing the COPY statement if there weren't any rows.
+func (cw *CopyWriter) Close() error {
+	if !cw.started {
+		if err := cw.Write(nil); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(cw.w, "\\.\n")
+	return err
+}
+
+// QuoteIdent quotes name as an identifier.
+func (d Dialect) QuoteIdent(name string) string {
+	quote := string(d.identQuote())
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+// Literal formats a scanned value as a SQL literal.
+// colType is its column's database type, as reported by the driver, if known.
+func (d Dialect) Literal(v interface{}, colType string) string {
+	switch v := plainValue(v).(type) {
+	case nil:
+		return "NULL"
+
+	case bool:
+		switch {
+		case d.NumericBools && v:
+			return "1"
+		case d.NumericBools:
+			return "0"
+		case v:
+			return "TRUE"
+		default:
+			return "FALSE"
+		}
+
+	case int64:
+		return strconv.FormatInt(v, 10)
+
+	case float64:
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			// e.g. postgres' 'NaN'::float8
+			return d.QuoteString(text)
+		}
+		return text
+
+	case decimal:
+		if v.isNumber() {
+			return string(v)
+		}
+		return d.QuoteString(string(v))
+
+	case string:
+		return d.QuoteString(v)
+
+	case time.Time:
+		return d.QuoteString(v.Format(d.timeLayout(colType)))
+
+	case []byte:
+		return d.bytesLiteral(v)
+
+	case driver.Valuer:
+		// e.g. sql.NullInt64, scanned by a driver's ScanType
+		val, err := v.Value()
+		if err != nil {
+			return d.QuoteString(fmt.Sprint(v))
+		}
+		return d.Literal(val, colType)
+
+	default:
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return fmt.Sprint(v)
+		}
+
+		// e.g. arrays
+		text, _ := textValue(v)
+		return d.QuoteString(text)
+	}
+}
+
+// QuoteString quotes s as a string literal.
+func (d Dialect) QuoteString(s string) string {
+	if d.BackslashEscapes {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (d Dialect) identQuote() byte {
+	if d.IdentQuote == 0 {
+		return '"'
+	}
+	return d.IdentQuote
+}
+
+func (d Dialect) identList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = d.QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (d Dialect) timeLayout(colType string) string {
+	if d.TimeLayout != "" {
+		return columnTimeLayout(colType, d.TimeLayout)
+	}
+	return columnTimeLayout(colType, "2006-01-02 15:04:05.999999999-07:00")
+}
+
+// columnTimeLayout returns the layout for columns of dates, or times of day,
+// which don't have all of a timestamp's parts. Otherwise, layout is returned.
+func columnTimeLayout(colType, layout string) string {
+	switch strings.ToUpper(colType) {
+	case "DATE":
+		return "2006-01-02"
+
+	case "TIME":
+		return "15:04:05.999999"
+
+	case "TIMETZ":
+		return "15:04:05.999999-07:00"
+
+	default:
+		return layout
+	}
+}
+
+func (d Dialect) bytesLiteral(b []byte) string {
+	if d.ByteaLiterals {
+		return `'\x` + hex.EncodeToString(b) + "'"
+	}
+	return "X'" + hex.EncodeToString(b) + "'"
+}
+
+// copyValue formats a scanned value for postgres' COPY text format.
+func (d Dialect) copyValue(v interface{}, colType string) string {
+	if t, ok := plainValue(v).(time.Time); ok {
+		return t.Format(d.timeLayout(colType))
+	}
+
+	text, ok := textValue(v)
+	if !ok {
+		return `\N`
+	}
+	return tsvEscaper.Replace(text)
+}
@@ -0,0 +1,126 @@
+package dbman
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Dialect(t *testing.T) {
+	result := &QueryResult{
+		Columns:     []string{"id", "name", "active", "created", "data"},
+		ColumnTypes: []string{"INT4", "TEXT", "BOOL", "DATE", "BYTEA"},
+		Rows: [][]interface{}{
+			{
+				nullInt32{sql.NullInt32{Int32: 1, Valid: true}},
+				nullString{sql.NullString{String: `it's C:\`, Valid: true}},
+				nullBool{sql.NullBool{Bool: true, Valid: true}},
+				nullTime{sql.NullTime{Time: time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC), Valid: true}},
+				[]byte("hi"),
+			},
+			{
+				nullInt32{sql.NullInt32{Int32: 2, Valid: true}},
+				nullValue{},
+				nullBool{sql.NullBool{Bool: false, Valid: true}},
+				nullTime{},
+				nullValue{},
+			},
+		},
+	}
+
+	postgres := Dialect{ByteaLiterals: true, Copy: true}
+	mysql := Dialect{IdentQuote: '`', BackslashEscapes: true}
+	sqlite := Dialect{NumericBools: true}
+
+	tests := []struct {
+		name  string
+		write func(sb *strings.Builder) error
+		want  string
+	}{
+		{
+			name:  "postgres inserts",
+			write: func(sb *strings.Builder) error { return postgres.WriteInserts(sb, "public.users", result) },
+			want: `INSERT INTO public.users ("id", "name", "active", "created", "data") VALUES (1, 'it''s C:\', TRUE, '2021-02-03', '\x6869');
+INSERT INTO public.users ("id", "name", "active", "created", "data") VALUES (2, NULL, FALSE, NULL, NULL);
+`,
+		},
+		{
+			name:  "mysql inserts",
+			write: func(sb *strings.Builder) error { return mysql.WriteInserts(sb, "users", result) },
+			want: "INSERT INTO users (`id`, `name`, `active`, `created`, `data`) VALUES (1, 'it''s C:\\\\', TRUE, '2021-02-03', X'6869');\n" +
+				"INSERT INTO users (`id`, `name`, `active`, `created`, `data`) VALUES (2, NULL, FALSE, NULL, NULL);\n",
+		},
+		{
+			name:  "sqlite updates",
+			write: func(sb *strings.Builder) error { return sqlite.WriteUpdates(sb, "users", []string{"id"}, result) },
+			want: `UPDATE users SET "name" = 'it''s C:\', "active" = 1, "created" = '2021-02-03', "data" = X'6869' WHERE "id" = 1;
+UPDATE users SET "name" = NULL, "active" = 0, "created" = NULL, "data" = NULL WHERE "id" = 2;
+`,
+		},
+		{
+			name:  "postgres copy",
+			write: func(sb *strings.Builder) error { return postgres.WriteCopy(sb, "users", result) },
+			want: "COPY users (\"id\", \"name\", \"active\", \"created\", \"data\") FROM stdin;\n" +
+				"1\tit's C:\\\\\ttrue\t2021-02-03\t\\\\x6869\n" +
+				"2\t\\N\tfalse\t\\N\t\\N\n" +
+				"\\.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := tt.write(&sb); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, sb.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if err := mysql.WriteCopy(&strings.Builder{}, "users", result); err == nil {
+		t.Error("expected an error writing COPY for a dialect without it")
+	}
+	if err := postgres.WriteUpdates(&strings.Builder{}, "users", []string{"nope"}, result); err == nil {
+		t.Error("expected an error for a key column that isn't in the result")
+	}
+}
+
+func Test_CopyWriter(t *testing.T) {
+	postgres := Dialect{Copy: true}
+
+	var sb strings.Builder
+	cw, err := postgres.NewCopyWriter(&sb, "events", []string{"id"}, []string{"INT8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more rows than fit in one batch
+	want := "COPY events (\"id\") FROM stdin;\n"
+	for start := int64(0); start < 150; start += 100 {
+		var batch [][]interface{}
+		for id := start; id < start+100 && id < 150; id++ {
+			batch = append(batch, []interface{}{nullInt64{sql.NullInt64{Int64: id, Valid: true}}})
+			want += fmt.Sprintf("%d\n", id)
+		}
+		if err := cw.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want += "\\.\n"
+
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("expected a single COPY block (-want +got):\n%s", diff)
+	}
+	if cw.Count() != 150 {
+		t.Errorf("expected 150 rows to be counted, but got %d", cw.Count())
+	}
+}
@@ -0,0 +1,189 @@
+package dbman
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultTimeLayout is how timestamps are displayed, unless configured otherwise.
+const defaultTimeLayout = "2006-01-02 15:04:05.999999999Z07:00"
+
+// Display controls how values are shown by the formats meant to be read (e.g. table),
+// rather than exported (e.g. csv).
+type Display struct {
+	Null           string `json:"null,omitempty"`            // optional, shown for NULL values, "NULL" if empty
+	TimeLayout     string `json:"time_layout,omitempty"`     // optional, Go time layout for timestamps
+	TimeZone       string `json:"time_zone,omitempty"`       // optional, e.g. "UTC", or "Local"; if empty, as returned by the driver
+	FloatPrecision int    `json:"float_precision,omitempty"` // optional, digits after the decimal point, 0 for as many as needed
+	MaxWidth       int    `json:"max_width,omitempty"`       // optional, values longer than this many characters are truncated, 0 for no limit
+	BoolStyle      string `json:"bool_style,omitempty"`      // optional, one of: true (the default), t, yes, 1
+
+	location *time.Location // TimeZone, loaded by validate, so that it isn't loaded per value
+}
+
+var boolStyles = map[string][2]string{
+	"":     {"true", "false"},
+	"true": {"true", "false"},
+	"t":    {"t", "f"},
+	"yes":  {"yes", "no"},
+	"1":    {"1", "0"},
+}
+
+// DisplaySettings returns the names of the settings that can be changed with Display.Set.
+func DisplaySettings() []string {
+	return []string{"null", "time_layout", "time_zone", "float_precision", "max_width", "bool_style"}
+}
+
+// Get returns the value of the named setting.
+func (d *Display) Get(name string) (string, error) {
+	switch name {
+	case "null":
+		return d.Null, nil
+	case "time_layout":
+		return d.TimeLayout, nil
+	case "time_zone":
+		return d.TimeZone, nil
+	case "float_precision":
+		return strconv.Itoa(d.FloatPrecision), nil
+	case "max_width":
+		return strconv.Itoa(d.MaxWidth), nil
+	case "bool_style":
+		return d.BoolStyle, nil
+	default:
+		return "", fmt.Errorf("unknown display setting '%s', must be one of: %s", name, strings.Join(DisplaySettings(), ", "))
+	}
+}
+
+// Set changes the named setting to value. The settings are left unchanged if value isn't valid.
+func (d *Display) Set(name, value string) error {
+	updated := *d
+	switch name {
+	case "null":
+		updated.Null = value
+	case "time_layout":
+		updated.TimeLayout = value
+	case "time_zone":
+		updated.TimeZone = value
+	case "bool_style":
+		updated.BoolStyle = value
+
+	case "float_precision", "max_width":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: must be a number", name)
+		}
+		if name == "float_precision" {
+			updated.FloatPrecision = n
+		} else {
+			updated.MaxWidth = n
+		}
+
+	default:
+		_, err := d.Get(name)
+		return err
+	}
+
+	if err := updated.validate("display"); err != nil {
+		return err
+	}
+	*d = updated
+	return nil
+}
+
+func (d *Display) validate(prefix string) error {
+	var errs errorList
+
+	d.location = nil
+	if d.TimeZone != "" {
+		loc, err := time.LoadLocation(d.TimeZone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.time_zone: %w", prefix, err))
+		}
+		d.location = loc
+	}
+
+	if d.FloatPrecision < 0 {
+		errs = append(errs, errors.New(prefix+".float_precision: must be greater than or equal to 0"))
+	}
+
+	if d.MaxWidth < 0 {
+		errs = append(errs, errors.New(prefix+".max_width: must be greater than or equal to 0"))
+	}
+
+	if _, ok := boolStyles[d.BoolStyle]; !ok {
+		errs = append(errs, errors.New(prefix+".bool_style: must be one of: true, t, yes, 1"))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Value formats a scanned value for display. colType is its column's database
+// type, as reported by the driver, if known.
+func (d *Display) Value(v interface{}, colType string) string {
+	var text string
+	switch v := plainValue(v).(type) {
+	case nil:
+		text = d.Null
+		if text == "" {
+			text = "NULL"
+		}
+
+	case bool:
+		style := boolStyles[d.BoolStyle]
+		if v {
+			text = style[0]
+		} else {
+			text = style[1]
+		}
+
+	case float64:
+		if d.FloatPrecision > 0 {
+			text = strconv.FormatFloat(v, 'f', d.FloatPrecision, 64)
+		} else {
+			text, _ = textValue(v)
+		}
+
+	case time.Time:
+		// dates and times of day aren't instants, so would change meaning in another zone
+		if d.TimeZone != "" && columnTimeLayout(colType, "") == "" {
+			if loc := d.timeZone(); loc != nil {
+				v = v.In(loc)
+			}
+		}
+
+		layout := d.TimeLayout
+		if layout == "" {
+			layout = defaultTimeLayout
+		}
+		text = v.Format(columnTimeLayout(colType, layout))
+
+	default:
+		text, _ = textValue(v)
+	}
+
+	if d.MaxWidth > 0 && utf8.RuneCountInString(text) > d.MaxWidth {
+		runes := []rune(text)
+		text = string(runes[:d.MaxWidth-1]) + "…"
+	}
+	return text
+}
+
+// timeZone returns the location named by TimeZone, or nil if it isn't valid.
+// It's only loaded here if d wasn't validated, e.g. if it was built in code.
+func (d *Display) timeZone() *time.Location {
+	if d.location != nil && d.location.String() == d.TimeZone {
+		return d.location
+	}
+	loc, err := time.LoadLocation(d.TimeZone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
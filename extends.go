@@ -0,0 +1,138 @@
+package dbman
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// lookupParent returns the connection, or template, named name, for a connection to extend.
+func (c *Config) lookupParent(name string) (Connection, bool) {
+	if conn, ok := c.Connections[name]; ok {
+		return conn, true
+	}
+	conn, ok := c.Templates[name]
+	return conn, ok
+}
+
+// resolveExtends returns conn, with any settings it doesn't have inherited from
+// its parent, which are first resolved in turn. chain is conn's name, and those
+// of the connections that extend it.
+func (c *Config) resolveExtends(conn Connection, chain []string) (Connection, error) {
+	if conn.Extends == "" {
+		return conn, nil
+	}
+
+	for _, name := range chain {
+		if name == conn.Extends {
+			return conn, fmt.Errorf("cycle: %s -> %s", strings.Join(chain, " -> "), conn.Extends)
+		}
+	}
+
+	parent, ok := c.lookupParent(conn.Extends)
+	if !ok {
+		return conn, fmt.Errorf("'%s' is not a connection, or template", conn.Extends)
+	}
+	parent, err := c.resolveExtends(parent, append(chain, conn.Extends))
+	if err != nil {
+		return conn, err
+	}
+
+	conn.inherit(&parent)
+	return conn, nil
+}
+
+// applyExtends resolves every connection that extends another, or a template.
+// Connections that can't be resolved are left as they are, for validateExtends to report.
+func (c *Config) applyExtends() {
+	resolved := make(map[string]Connection, len(c.Connections))
+	for k, v := range c.Connections {
+		if conn, err := c.resolveExtends(v, []string{k}); err == nil {
+			resolved[k] = conn
+		}
+	}
+	for k, v := range resolved {
+		c.Connections[k] = v
+	}
+}
+
+func (c *Config) validateExtends() error {
+	var errs errorList
+
+	for k, v := range c.Templates {
+		if _, ok := c.Connections[k]; ok {
+			errs = append(errs, errors.New("templates."+k+": a connection has the same name"))
+		}
+		if _, err := c.resolveExtends(v, []string{k}); err != nil {
+			errs = append(errs, errors.New("templates."+k+".extends: "+err.Error()))
+		}
+	}
+
+	for k, v := range c.Connections {
+		if _, err := c.resolveExtends(v, []string{k}); err != nil {
+			errs = append(errs, errors.New(k+".extends: "+err.Error()))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// inherit sets any of c's settings that aren't set from parent.
+// driver_opts are inherited individually.
+func (c *Connection) inherit(parent *Connection) {
+	if c.Service == "" {
+		c.Service = parent.Service
+	}
+	if c.DSN == "" {
+		c.DSN = parent.DSN
+	}
+	if c.Host == "" {
+		c.Host = parent.Host
+	}
+	if c.Port == 0 {
+		c.Port = parent.Port
+	}
+	if c.Database == "" {
+		c.Database = parent.Database
+	}
+	if c.Username == "" {
+		c.Username = parent.Username
+	}
+	if c.Password.Value == "" && c.Password.From == "" {
+		c.Password = parent.Password
+	}
+	if c.Driver == "" {
+		c.Driver = parent.Driver
+	}
+	if len(parent.DriverOpts) != 0 {
+		opts := make(map[string]string, len(parent.DriverOpts)+len(c.DriverOpts))
+		for k, v := range parent.DriverOpts {
+			opts[k] = v
+		}
+		for k, v := range c.DriverOpts {
+			opts[k] = v
+		}
+		c.DriverOpts = opts
+	}
+	if c.Tunnel == "" {
+		c.Tunnel = parent.Tunnel
+	}
+	if c.ConnectTimeoutSec == 0 {
+		c.ConnectTimeoutSec = parent.ConnectTimeoutSec
+	}
+	if c.QueryTimeoutSec == 0 {
+		c.QueryTimeoutSec = parent.QueryTimeoutSec
+	}
+	if c.ReadOnly == nil {
+		c.ReadOnly = parent.ReadOnly
+	}
+	if c.ConfirmWrites == nil {
+		c.ConfirmWrites = parent.ConfirmWrites
+	}
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = parent.MaxOpenConns
+	}
+}
@@ -0,0 +1,141 @@
+package dbman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Config_applyExtends(t *testing.T) {
+	cfg := Config{
+		Templates: map[string]Connection{
+			"base": {
+				Driver:     "postgres",
+				Port:       5432,
+				Password:   Secret{From: "pgpass"},
+				DriverOpts: map[string]string{"sslmode": "verify-full", "application_name": "dbman"},
+				ReadOnly:   boolPtr(true),
+			},
+			"base-prod": {Extends: "base", Username: "reporting", Tunnel: "bastion"},
+		},
+		Connections: map[string]Connection{
+			"eu": {Extends: "base-prod", Host: "eu.example.com", Database: "tenant_eu"},
+			"eu-admin": {
+				Extends:    "eu",
+				Username:   "admin",
+				Password:   Secret{Value: "hunter2"},
+				DriverOpts: map[string]string{"sslmode": "require"},
+			},
+			"eu-writer": {Extends: "eu", ReadOnly: boolPtr(false)},
+			"local":     {Driver: "sqlite", Database: ":memory:"},
+		},
+		Tunnels: map[string]SSHTunnel{
+			"bastion": {Host: "bastion.example.com", Port: 22, User: "me", AuthMethod: AgentAuth},
+		},
+	}
+
+	cfg.applyExtends()
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]Connection{
+		"eu": {
+			Extends:    "base-prod",
+			Host:       "eu.example.com",
+			Port:       5432,
+			Database:   "tenant_eu",
+			Username:   "reporting",
+			Password:   Secret{From: "pgpass"},
+			Driver:     "postgres",
+			DriverOpts: map[string]string{"sslmode": "verify-full", "application_name": "dbman"},
+			Tunnel:     "bastion",
+			ReadOnly:   boolPtr(true),
+		},
+		"eu-admin": {
+			Extends:    "eu",
+			Host:       "eu.example.com",
+			Port:       5432,
+			Database:   "tenant_eu",
+			Username:   "admin",
+			Password:   Secret{Value: "hunter2"},
+			Driver:     "postgres",
+			DriverOpts: map[string]string{"sslmode": "require", "application_name": "dbman"},
+			Tunnel:     "bastion",
+			ReadOnly:   boolPtr(true),
+		},
+		"eu-writer": {
+			Extends:    "eu",
+			Host:       "eu.example.com",
+			Port:       5432,
+			Database:   "tenant_eu",
+			Username:   "reporting",
+			Password:   Secret{From: "pgpass"},
+			Driver:     "postgres",
+			DriverOpts: map[string]string{"sslmode": "verify-full", "application_name": "dbman"},
+			Tunnel:     "bastion",
+			ReadOnly:   boolPtr(false),
+		},
+		"local": {Driver: "sqlite", Database: ":memory:"},
+	}
+	if diff := cmp.Diff(expect, cfg.Connections); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(map[string]string{"sslmode": "verify-full", "application_name": "dbman"}, cfg.Templates["base"].DriverOpts); diff != "" {
+		t.Error("expected the template not to be modified:", diff)
+	}
+}
+
+func Test_Config_validateExtends(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       Config
+		expectErr string
+	}{
+		{
+			name: "missing parent",
+			cfg: Config{
+				Connections: map[string]Connection{"a": {Extends: "nope"}},
+			},
+			expectErr: "a.extends: 'nope' is not a connection, or template",
+		},
+		{
+			name: "cycle",
+			cfg: Config{
+				Connections: map[string]Connection{"a": {Extends: "b"}},
+				Templates:   map[string]Connection{"b": {Extends: "c"}, "c": {Extends: "b"}},
+			},
+			expectErr: "a.extends: cycle: a -> b -> c -> b",
+		},
+		{
+			name: "self",
+			cfg: Config{
+				Connections: map[string]Connection{"a": {Extends: "a"}},
+			},
+			expectErr: "a.extends: cycle: a -> a",
+		},
+		{
+			name: "template named like a connection",
+			cfg: Config{
+				Connections: map[string]Connection{"a": {}},
+				Templates:   map[string]Connection{"a": {}},
+			},
+			expectErr: "templates.a: a connection has the same name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.applyExtends()
+			err := tt.cfg.validateExtends()
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("expected %q, but got: %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,624 @@
+package dbman
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Formatter writes a QueryResult's columns and rows to w.
+// Formats meant to be read (e.g. table) show values according to display, while
+// formats meant for exporting data (e.g. csv) ignore it.
+type Formatter func(w io.Writer, result *QueryResult, display *Display) error
+
+// format writes what comes before, for, and after a result's rows separately, so
+// that the rows can be written a batch at a time.
+// first is the number of rows written by earlier batches.
+type format struct {
+	header  func(w io.Writer, result *QueryResult) error // optional
+	rows    rowsFunc
+	newRows func() rowsFunc         // optional, for formats that keep state between batches
+	footer  func(w io.Writer) error // optional
+}
+
+// rowsFunc writes a batch of rows.
+type rowsFunc func(w io.Writer, result *QueryResult, display *Display, first int) error
+
+var formats = map[string]format{
+	"table":    {newRows: newTableRows},
+	"expanded": {rows: formatExpanded},
+	"csv":      {header: csvHeader, rows: formatCSV},
+	"tsv":      {header: tsvHeader, rows: formatTSV},
+	"json":     {header: jsonHeader, rows: formatJSON, footer: jsonFooter},
+	"ndjson":   {rows: formatNDJSON},
+	"markdown": {header: markdownHeader, rows: formatMarkdown},
+	"html":     {header: htmlHeader, rows: formatHTML, footer: htmlFooter},
+}
+
+// Formats returns a sorted list of the names of the supported formats.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupFormat returns the named Formatter.
+func LookupFormat(name string) (Formatter, bool) {
+	if _, ok := formats[strings.ToLower(name)]; !ok {
+		return nil, false
+	}
+	return func(w io.Writer, result *QueryResult, display *Display) error {
+		return WriteResult(w, name, result, display)
+	}, true
+}
+
+// WriteResult writes result to w in the named format.
+// If display is nil, values are shown with the default settings.
+func WriteResult(w io.Writer, format string, result *QueryResult, display *Display) error {
+	rw, err := NewResultWriter(w, format, result.Columns, result.ColumnTypes, display)
+	if err != nil {
+		return err
+	}
+	if err := rw.Write(result.Rows); err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+// ResultWriter writes a result in a format a batch of rows at a time, so that rows
+// can be written as they're read, without holding on to all of them.
+// Table columns are sized by the first batch, and later values too wide for them are truncated.
+type ResultWriter struct {
+	w       io.Writer
+	format  format
+	display *Display
+	result  QueryResult // the columns, and the batch being written
+	count   int         // rows written so far
+	started bool
+}
+
+// NewResultWriter returns a ResultWriter for a result with columns, in the named format.
+// If display is nil, values are shown with the default settings.
+func NewResultWriter(w io.Writer, format string, columns, columnTypes []string, display *Display) (*ResultWriter, error) {
+	f, ok := formats[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown format '%s'", format)
+	}
+	if display == nil {
+		display = &Display{}
+	}
+	if f.newRows != nil {
+		f.rows = f.newRows()
+	}
+	return &ResultWriter{
+		w:       w,
+		format:  f,
+		display: display,
+		result:  QueryResult{Columns: columns, ColumnTypes: columnTypes},
+	}, nil
+}
+
+// Write writes a batch of rows, after the header, if it hasn't been written yet.
+func (rw *ResultWriter) Write(rows [][]interface{}) error {
+	if rw.started && len(rows) == 0 {
+		return nil
+	}
+	if !rw.started && rw.format.header != nil {
+		if err := rw.format.header(rw.w, &rw.result); err != nil {
+			return err
+		}
+	}
+	rw.started = true
+
+	rw.result.Rows = rows
+	defer func() { rw.result.Rows = nil }()
+
+	if err := rw.format.rows(rw.w, &rw.result, rw.display, rw.count); err != nil {
+		return err
+	}
+	rw.count += len(rows)
+	return nil
+}
+
+// Count returns the number of rows written so far.
+func (rw *ResultWriter) Count() int {
+	return rw.count
+}
+
+// Close writes anything that follows the rows, e.g. the end of a JSON array.
+// The header is written if it hasn't been already, i.e. if there weren't any rows.
+func (rw *ResultWriter) Close() error {
+	if !rw.started {
+		if err := rw.Write(nil); err != nil {
+			return err
+		}
+	}
+	if rw.format.footer != nil {
+		return rw.format.footer(rw.w)
+	}
+	return nil
+}
+
+// newTableRows returns a rowsFunc that aligns rows under their column names, which are only
+// written with the first batch. Columns are as wide as their name or widest value in the first
+// batch, and later values that don't fit are truncated, so that every batch lines up.
+func newTableRows() rowsFunc {
+	var widths []int
+	return func(w io.Writer, result *QueryResult, display *Display, first int) error {
+		cells := make([][]string, len(result.Rows))
+		for n, row := range result.Rows {
+			cells[n] = make([]string, len(row))
+			for i, val := range row {
+				cells[n][i] = display.Value(val, result.ColumnType(i))
+			}
+		}
+
+		var sb strings.Builder
+		if widths == nil {
+			widths = make([]int, len(result.Columns))
+			for i, col := range result.Columns {
+				widths[i] = utf8.RuneCountInString(col)
+				for _, row := range cells {
+					if n := utf8.RuneCountInString(row[i]); n > widths[i] {
+						widths[i] = n
+					}
+				}
+			}
+
+			header := tableLine(result.Columns, widths)
+			sb.WriteString(header)
+			sb.WriteString(strings.Repeat("-", utf8.RuneCountInString(header)-1) + "\n")
+		}
+
+		for _, row := range cells {
+			sb.WriteString(tableLine(row, widths))
+		}
+
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+}
+
+// tableLine pads or truncates each cell but the last to its column's width, and separates them with '|'.
+func tableLine(cells []string, widths []int) string {
+	var sb strings.Builder
+	for i, cell := range cells {
+		sb.WriteByte(' ')
+		if i == len(cells)-1 {
+			sb.WriteString(cell)
+			break
+		}
+		sb.WriteString(fitWidth(cell, widths[i]))
+		sb.WriteString(" |")
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// fitWidth pads s with spaces to width runes, or truncates it to fit, ending it with "…".
+func fitWidth(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	if width == 0 {
+		return ""
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+// formatExpanded writes each row as a block of "column | value" lines, like psql's \x.
+func formatExpanded(w io.Writer, result *QueryResult, display *Display, first int) error {
+	var nameWidth int
+	for _, col := range result.Columns {
+		if n := utf8.RuneCountInString(col); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	// continuation lines of multi-line values are aligned with the first
+	continuation := "\n" + strings.Repeat(" ", nameWidth) + " | "
+
+	var sb strings.Builder
+	lines := make([]string, len(result.Columns))
+	for n, row := range result.Rows {
+		lineWidth := 0
+		for i, col := range result.Columns {
+			value := display.Value(row[i], result.ColumnType(i))
+			lines[i] = col + strings.Repeat(" ", nameWidth-utf8.RuneCountInString(col)) + " | " + strings.ReplaceAll(value, "\n", continuation)
+
+			for _, line := range strings.Split(lines[i], "\n") {
+				if n := utf8.RuneCountInString(line); n > lineWidth {
+					lineWidth = n
+				}
+			}
+		}
+
+		header := fmt.Sprintf("-[ RECORD %d ]", first+n+1)
+		if pad := lineWidth - len(header); pad > 0 {
+			header += strings.Repeat("-", pad)
+		}
+		sb.WriteString(header + "\n")
+		sb.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func csvHeader(w io.Writer, result *QueryResult) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(result.Columns); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatCSV writes NULLs as empty fields.
+func formatCSV(w io.Writer, result *QueryResult, _ *Display, _ int) error {
+	writer := csv.NewWriter(w)
+
+	record := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, val := range row {
+			record[i], _ = textValue(val)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// tsvEscaper escapes values like postgres' COPY text format.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func tsvHeader(w io.Writer, result *QueryResult) error {
+	fields := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		fields[i] = tsvEscaper.Replace(col)
+	}
+	_, err := io.WriteString(w, strings.Join(fields, "\t")+"\n")
+	return err
+}
+
+// formatTSV writes NULLs as \N, like postgres' COPY text format.
+func formatTSV(w io.Writer, result *QueryResult, _ *Display, _ int) error {
+	fields := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, val := range row {
+			if text, ok := textValue(val); ok {
+				fields[i] = tsvEscaper.Replace(text)
+			} else {
+				fields[i] = `\N`
+			}
+		}
+		if _, err := io.WriteString(w, strings.Join(fields, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonHeader(w io.Writer, _ *QueryResult) error {
+	_, err := io.WriteString(w, "[")
+	return err
+}
+
+// formatJSON writes an array of objects, one per row, keyed by column name.
+func formatJSON(w io.Writer, result *QueryResult, _ *Display, first int) error {
+	for i, row := range result.Rows {
+		if first+i != 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "\n  "); err != nil {
+			return err
+		}
+		if err := writeJSONObject(w, result.Columns, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonFooter(w io.Writer) error {
+	_, err := io.WriteString(w, "\n]\n")
+	return err
+}
+
+// formatNDJSON writes an object per line, one per row, keyed by column name.
+func formatNDJSON(w io.Writer, result *QueryResult, _ *Display, _ int) error {
+	for _, row := range result.Rows {
+		if err := writeJSONObject(w, result.Columns, row); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSONObject keeps the columns in order, which a map wouldn't.
+func writeJSONObject(w io.Writer, columns []string, row []interface{}) error {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, col := range columns {
+		if i != 0 {
+			sb.WriteByte(',')
+		}
+
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(jsonValue(plainValue(row[i])))
+		if err != nil {
+			return fmt.Errorf("column '%s': %w", col, err)
+		}
+
+		sb.Write(key)
+		sb.WriteByte(':')
+		sb.Write(val)
+	}
+	sb.WriteByte('}')
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		// not representable in JSON
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return v
+
+	case decimal:
+		if v.isNumber() {
+			return json.Number(v)
+		}
+		return string(v)
+
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+
+	case []byte:
+		text, _ := textValue(v)
+		return text
+
+	case []interface{}:
+		vals := make([]interface{}, len(v))
+		for i, e := range v {
+			vals[i] = jsonValue(e)
+		}
+		return vals
+
+	default:
+		return v
+	}
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func markdownHeader(w io.Writer, result *QueryResult) error {
+	var sb strings.Builder
+
+	sb.WriteByte('|')
+	for _, col := range result.Columns {
+		sb.WriteString(" " + markdownEscaper.Replace(col) + " |")
+	}
+	sb.WriteString("\n|")
+	for range result.Columns {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteByte('\n')
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatMarkdown(w io.Writer, result *QueryResult, display *Display, _ int) error {
+	var sb strings.Builder
+	for _, row := range result.Rows {
+		sb.WriteByte('|')
+		for i, val := range row {
+			sb.WriteString(" " + markdownEscaper.Replace(display.Value(val, result.ColumnType(i))) + " |")
+		}
+		sb.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func htmlHeader(w io.Writer, result *QueryResult) error {
+	var sb strings.Builder
+
+	sb.WriteString("<table>\n<thead>\n<tr>")
+	for _, col := range result.Columns {
+		sb.WriteString("<th>" + html.EscapeString(col) + "</th>")
+	}
+	sb.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatHTML(w io.Writer, result *QueryResult, display *Display, _ int) error {
+	var sb strings.Builder
+	for _, row := range result.Rows {
+		sb.WriteString("<tr>")
+		for i, val := range row {
+			class := ""
+			if plainValue(val) == nil {
+				class = ` class="null"`
+			}
+			sb.WriteString("<td" + class + ">" + html.EscapeString(display.Value(val, result.ColumnType(i))) + "</td>")
+		}
+		sb.WriteString("</tr>\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func htmlFooter(w io.Writer) error {
+	_, err := io.WriteString(w, "</tbody>\n</table>\n")
+	return err
+}
+
+// plainValue unwraps a scanned value into nil (for NULL), or a bool, int64, float64,
+// decimal, string, time.Time, json.RawMessage, []byte (binary data), or []interface{}.
+// Values without an equivalent (e.g. intervals) are formatted as strings.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, nullValue:
+		return nil
+
+	case nullString:
+		if v.Valid {
+			return v.NullString.String
+		}
+		return nil
+
+	case nullBool:
+		if v.Valid {
+			return v.Bool
+		}
+		return nil
+
+	case nullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+		return nil
+
+	case nullInt32:
+		if v.Valid {
+			return int64(v.Int32)
+		}
+		return nil
+
+	case nullInt16:
+		if v.Valid {
+			return int64(v.Int16)
+		}
+		return nil
+
+	case nullFloat64:
+		if v.Valid {
+			return v.Float64
+		}
+		return nil
+
+	case nullFloat32:
+		if v.Valid {
+			return float64(v.Float32)
+		}
+		return nil
+
+	case nullTime:
+		if v.Valid {
+			return v.Time
+		}
+		return nil
+
+	case nullDecimal:
+		if v.Valid {
+			return v.Decimal
+		}
+		return nil
+
+	case uuidVal, nullInterval, nullRange, nullInet:
+		if text := v.(fmt.Stringer).String(); text != "NULL" {
+			return text
+		}
+		return nil
+
+	case nullJSON:
+		switch {
+		case !v.Valid:
+			return nil
+		case json.Valid(v.JSON):
+			return json.RawMessage(v.JSON)
+		default:
+			return string(v.JSON)
+		}
+
+	case nullBytes:
+		if v.Valid {
+			return v.Bytes
+		}
+		return nil
+
+	case nullArray:
+		if v.Valid {
+			return plainValue(v.Elems)
+		}
+		return nil
+
+	case []interface{}:
+		vals := make([]interface{}, len(v))
+		for i, e := range v {
+			vals[i] = plainValue(e)
+		}
+		return vals
+
+	case int:
+		return int64(v)
+
+	case float32:
+		return float64(v)
+
+	default:
+		return v
+	}
+}
+
+// textValue formats a scanned value for text based formats.
+// ok is false if v is NULL.
+func textValue(v interface{}) (text string, ok bool) {
+	switch v := plainValue(v).(type) {
+	case nil:
+		return "", false
+
+	case string:
+		return v, true
+
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+
+	case []byte:
+		return nullBytes{Bytes: v, Valid: true}.String(), true
+
+	case json.RawMessage:
+		return nullJSON{JSON: v, Valid: true}.String(), true
+
+	case []interface{}:
+		return arrayText(v), true
+
+	default:
+		return fmt.Sprint(v), true
+	}
+}
@@ -0,0 +1,239 @@
+package dbman
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_WriteResult(t *testing.T) {
+	result := &QueryResult{
+		Columns: []string{"id", "name", "created", "tags"},
+		Rows: [][]interface{}{
+			{
+				uuidVal{UUID: [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}, Valid: true},
+				nullString{sql.NullString{String: "a|b,\"c\"\td", Valid: true}},
+				nullTime{sql.NullTime{Time: time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC), Valid: true}},
+				[]interface{}{int64(1), nil},
+			},
+			{
+				uuidVal{},
+				nullValue{},
+				nullTime{},
+				nullValue{},
+			},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "table",
+			want: " id                                   | name      | created              | tags\n" +
+				"-------------------------------------------------------------------------------\n" +
+				" 12345678-9abc-def0-1234-56789abcdef0 | a|b,\"c\"\td | 2021-02-03 04:05:06Z | {1,NULL}\n" +
+				" NULL                                 | NULL      | NULL                 | NULL\n",
+		},
+		{
+			format: "csv",
+			want: `id,name,created,tags
+12345678-9abc-def0-1234-56789abcdef0,"a|b,""c""` + "\t" + `d",2021-02-03T04:05:06Z,"{1,NULL}"
+,,,
+`,
+		},
+		{
+			format: "tsv",
+			want: "id\tname\tcreated\ttags\n" +
+				"12345678-9abc-def0-1234-56789abcdef0\ta|b,\"c\"\\td\t2021-02-03T04:05:06Z\t{1,NULL}\n" +
+				"\\N\t\\N\t\\N\t\\N\n",
+		},
+		{
+			format: "json",
+			want: `[
+  {"id":"12345678-9abc-def0-1234-56789abcdef0","name":"a|b,\"c\"\td","created":"2021-02-03T04:05:06Z","tags":[1,null]},
+  {"id":null,"name":null,"created":null,"tags":null}
+]
+`,
+		},
+		{
+			format: "ndjson",
+			want: `{"id":"12345678-9abc-def0-1234-56789abcdef0","name":"a|b,\"c\"\td","created":"2021-02-03T04:05:06Z","tags":[1,null]}
+{"id":null,"name":null,"created":null,"tags":null}
+`,
+		},
+		{
+			format: "markdown",
+			want: `| id | name | created | tags |
+| --- | --- | --- | --- |
+| 12345678-9abc-def0-1234-56789abcdef0 | a\|b,"c"` + "\t" + `d | 2021-02-03 04:05:06Z | {1,NULL} |
+| NULL | NULL | NULL | NULL |
+`,
+		},
+		{
+			format: "expanded",
+			want: "-[ RECORD 1 ]" + strings.Repeat("-", 33) + `
+id      | 12345678-9abc-def0-1234-56789abcdef0
+name    | a|b,"c"` + "\t" + `d
+created | 2021-02-03 04:05:06Z
+tags    | {1,NULL}
+-[ RECORD 2 ]-
+id      | NULL
+name    | NULL
+created | NULL
+tags    | NULL
+`,
+		},
+		{
+			format: "html",
+			want: `<table>
+<thead>
+<tr><th>id</th><th>name</th><th>created</th><th>tags</th></tr>
+</thead>
+<tbody>
+<tr><td>12345678-9abc-def0-1234-56789abcdef0</td><td>a|b,&#34;c&#34;` + "\t" + `d</td><td>2021-02-03 04:05:06Z</td><td>{1,NULL}</td></tr>
+<tr><td class="null">NULL</td><td class="null">NULL</td><td class="null">NULL</td><td class="null">NULL</td></tr>
+</tbody>
+</table>
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var sb strings.Builder
+			if err := WriteResult(&sb, tt.format, result, nil); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, sb.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if err := WriteResult(&strings.Builder{}, "nope", result, nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func Test_ResultWriter(t *testing.T) {
+	result := &QueryResult{
+		Columns: []string{"id", "name"},
+		Rows: [][]interface{}{
+			{int64(1), nullString{sql.NullString{String: "alice", Valid: true}}},
+			{int64(2), nullValue{}},
+			{int64(3), nullString{sql.NullString{String: "carol", Valid: true}}},
+		},
+	}
+
+	for _, format := range Formats() {
+		t.Run(format, func(t *testing.T) {
+			var want strings.Builder
+			if err := WriteResult(&want, format, result, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var sb strings.Builder
+			rw, err := NewResultWriter(&sb, format, result.Columns, result.ColumnTypes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, batch := range [][][]interface{}{result.Rows[:2], nil, result.Rows[2:]} {
+				if err := rw.Write(batch); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := rw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(want.String(), sb.String()); diff != "" {
+				t.Errorf("expected the same output as a single batch (-want +got):\n%s", diff)
+			}
+			if rw.Count() != len(result.Rows) {
+				t.Errorf("expected %d rows to be written, but got %d", len(result.Rows), rw.Count())
+			}
+		})
+	}
+}
+
+func Test_ResultWriter_table(t *testing.T) {
+	name := func(s string) nullString { return nullString{sql.NullString{String: s, Valid: true}} }
+
+	var sb strings.Builder
+	rw, err := NewResultWriter(&sb, "table", []string{"name", "id"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := [][][]interface{}{
+		{{name("alice"), int64(1)}},
+		{{name("bo"), int64(2)}, {name("christopher"), int64(3)}},
+	}
+	for _, batch := range batches {
+		if err := rw.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := " name  | id\n" +
+		"-----------\n" +
+		" alice | 1\n" +
+		" bo    | 2\n" +
+		" chri… | 3\n"
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("expected later batches to keep the first's widths (-want +got):\n%s", diff)
+	}
+}
+
+func Test_Display_Value(t *testing.T) {
+	created := nullTime{sql.NullTime{Time: time.Date(2021, 2, 3, 23, 5, 6, 0, time.UTC), Valid: true}}
+
+	tests := []struct {
+		name    string
+		display Display
+		val     interface{}
+		colType string
+		want    string
+	}{
+		{name: "null", val: nullValue{}, want: "NULL"},
+		{name: "null marker", display: Display{Null: "∅"}, val: nullString{}, want: "∅"},
+		{name: "string 'NULL' with marker", display: Display{Null: "∅"}, val: nullString{sql.NullString{String: "NULL", Valid: true}}, want: "NULL"},
+		{name: "bool", val: nullBool{sql.NullBool{Bool: true, Valid: true}}, want: "true"},
+		{name: "bool style", display: Display{BoolStyle: "t"}, val: nullBool{sql.NullBool{Bool: false, Valid: true}}, want: "f"},
+		{name: "float", val: nullFloat64{sql.NullFloat64{Float64: 1.0 / 3, Valid: true}}, want: "0.3333333333333333"},
+		{name: "float precision", display: Display{FloatPrecision: 2}, val: nullFloat64{sql.NullFloat64{Float64: 1.0 / 3, Valid: true}}, want: "0.33"},
+		{name: "decimal ignores float precision", display: Display{FloatPrecision: 2}, val: nullDecimal{Decimal: "1.23456", Valid: true}, want: "1.23456"},
+		{name: "timestamp", val: created, colType: "TIMESTAMPTZ", want: "2021-02-03 23:05:06Z"},
+		{name: "time layout", display: Display{TimeLayout: time.RFC1123}, val: created, colType: "TIMESTAMPTZ", want: "Wed, 03 Feb 2021 23:05:06 UTC"},
+		{name: "time zone", display: Display{TimeZone: "Asia/Tokyo"}, val: created, colType: "TIMESTAMPTZ", want: "2021-02-04 08:05:06+09:00"},
+		{name: "date ignores time zone", display: Display{TimeZone: "Asia/Tokyo"}, val: created, colType: "DATE", want: "2021-02-03"},
+		{name: "max width", display: Display{MaxWidth: 5}, val: nullString{sql.NullString{String: "abcdefgh", Valid: true}}, want: "abcd…"},
+		{name: "within max width", display: Display{MaxWidth: 5}, val: nullString{sql.NullString{String: "abcde", Valid: true}}, want: "abcde"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.display.Value(tt.val, tt.colType); got != tt.want {
+				t.Errorf("expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+
+	var display Display
+	if err := display.Set("bool_style", "maybe"); err == nil {
+		t.Error("expected an error for an unknown bool_style")
+	}
+	if err := display.Set("max_width", "10"); err != nil || display.MaxWidth != 10 {
+		t.Errorf("expected max_width to be set to 10, but got %d (%v)", display.MaxWidth, err)
+	}
+	if err := display.Set("time_zone", "Asia/Tokyo"); err != nil || display.location == nil || display.location.String() != "Asia/Tokyo" {
+		t.Errorf("expected time_zone's location to be loaded once it's set, but got %v (%v)", display.location, err)
+	}
+}
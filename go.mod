@@ -3,12 +3,16 @@ module dabbertorres.dev/dbman
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v0.3.0
 	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/mock v1.4.4
 	github.com/google/go-cmp v0.5.4
 	github.com/google/uuid v1.2.0
 	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/neovim/go-client v1.1.5
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 	golang.org/x/term v0.0.0-20201117132131-f5c789dd3221
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -22,50 +22,119 @@ type TableSchema struct {
 	Columns []ColumnSchema
 }
 
-type querier interface {
+// Querier is the subset of *sql.DB used by DBMan.
+type Querier interface {
 	PingContext(context.Context) error
-	Query(string, ...interface{}) (*sql.Rows, error)
+	Conn(context.Context) (*sql.Conn, error)
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
 	Stats() sql.DBStats
 	Close() error
 }
 
-type metaQuerier interface {
-	querier
-	ListTables() ([]string, error)
-	ListTablesInSchema(string) ([]string, error)
-	ListSchemas() ([]string, error)
-	DescribeTable(string) (*TableSchema, error)
+// MetaQuerier is implemented by each Backend to inspect the structure of its databases.
+type MetaQuerier interface {
+	Querier
+	ListTables(context.Context) ([]string, error)
+	ListTablesInSchema(context.Context, string) ([]string, error)
+	ListSchemas(context.Context) ([]string, error)
+	DescribeTable(context.Context, string) (*TableSchema, error)
 }
 
-func postgresOpen(conn *Connection) (*sql.DB, error) {
-	sslmode, ok := conn.DriverOpts["sslmode"]
-	if !ok {
-		sslmode = "require"
+func init() {
+	RegisterBackend("postgres", Backend{
+		Connector:   postgresConnector,
+		Validate:    postgresValidate,
+		Meta:        func(db Querier) MetaQuerier { return dbMeta{db} },
+		Notices:     postgresNotices,
+		Placeholder: dollarPlaceholder,
+		Dialect: Dialect{
+			ByteaLiterals: true,
+			TimeLayout:    "2006-01-02 15:04:05.999999-07:00",
+			Copy:          true,
+		},
+		DSNPassword:    postgresDSNPassword,
+		PasswordEnv:    "PGPASSWORD",
+		LookupPassword: lookupPgpass,
+	})
+}
+
+func postgresConnector(conn *Connection) (driver.Connector, error) {
+	settings, err := postgresSettings(conn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dsn: %w", err)
 	}
-	dsn := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=%s",
-		conn.Host,
-		conn.Port,
-		conn.Database,
-		conn.Username,
-		conn.Password,
-		sslmode,
-	)
-	connector, err := pq.NewConnector(dsn)
+
+	// lib/pq doesn't support target_session_attrs, so it's checked after connecting
+	attrs, ok := settings["target_session_attrs"]
+	delete(settings, "target_session_attrs")
+
+	connector, err := pq.NewConnector(formatConninfo(settings))
 	if err != nil {
 		return nil, err
 	}
-	db := sql.OpenDB(connector)
-	return db, nil
+	if ok {
+		return targetSessionConnector{Connector: connector, attrs: attrs}, nil
+	}
+	return connector, nil
+}
+
+// postgresValidate requires either a dsn, or the usual host, port, database, and username.
+func postgresValidate(prefix string, conn *Connection) error {
+	var errs errorList
+
+	if conn.DSN == "" {
+		if err := conn.validateNetwork(prefix); err != nil {
+			errs = append(errs, err)
+		}
+	} else if conn.Tunnel != "" {
+		// the tunnel is to the host and port, not from the dsn
+		if conn.Host == "" {
+			errs = append(errs, errors.New(prefix+".host: required to use a tunnel"))
+		}
+		if conn.Port == 0 {
+			errs = append(errs, errors.New(prefix+".port: required to use a tunnel"))
+		}
+	}
+
+	if settings, err := postgresSettings(conn); err != nil {
+		errs = append(errs, errors.New(prefix+".dsn: "+err.Error()))
+	} else if attrs, ok := settings["target_session_attrs"]; ok {
+		if err := validateTargetSessionAttrs(attrs); err != nil {
+			errs = append(errs, errors.New(prefix+".target_session_attrs: "+err.Error()))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// postgresDSNPassword returns the password in conn's dsn, if it has one.
+func postgresDSNPassword(conn *Connection) (string, bool, error) {
+	settings, err := parseConninfo(conn.DSN)
+	if err != nil {
+		return "", false, err
+	}
+	password, ok := settings["password"]
+	return password, ok, nil
+}
+
+func postgresNotices(connector driver.Connector, handler func(string)) driver.Connector {
+	return pq.ConnectorWithNoticeHandler(connector, func(notice *pq.Error) {
+		handler(notice.Severity + ": " + notice.Message)
+	})
 }
 
 type dbMeta struct {
-	querier
+	Querier
 }
 
 // TODO use SELECT CURRENT_SCHEMA() to decide when and when not to join schema names to table names
 
-func (m dbMeta) ListTables() ([]string, error) {
-	rows, err := m.Query(`SELECT format('%s.%s', table_schema, table_name) FROM information_schema.tables
+func (m dbMeta) ListTables(ctx context.Context) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT format('%s.%s', table_schema, table_name) FROM information_schema.tables
                           WHERE table_schema NOT LIKE 'pg_%'
                           AND table_schema <> 'information_schema'
                           ORDER BY table_schema, table_name`)
@@ -86,8 +155,8 @@ func (m dbMeta) ListTables() ([]string, error) {
 	return tables, nil
 }
 
-func (m dbMeta) ListTablesInSchema(schema string) ([]string, error) {
-	rows, err := m.Query(`SELECT table_name FROM information_schema.tables
+func (m dbMeta) ListTablesInSchema(ctx context.Context, schema string) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT table_name FROM information_schema.tables
                           WHERE table_schema = $1
                           ORDER BY table_name`, schema)
 	if err != nil {
@@ -123,8 +192,8 @@ var (
 	}()
 )
 
-func (m dbMeta) ListSchemas() ([]string, error) {
-	rows, err := m.Query(`SELECT schema_name FROM information_schema.schemata
+func (m dbMeta) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT schema_name FROM information_schema.schemata
                           WHERE schema_name NOT LIKE 'pg_%'
                           AND schema_name <> 'information_schema'`)
 	if err != nil {
@@ -144,7 +213,7 @@ func (m dbMeta) ListSchemas() ([]string, error) {
 	return schemas, nil
 }
 
-func (m dbMeta) DescribeTable(tablename string) (*TableSchema, error) {
+func (m dbMeta) DescribeTable(ctx context.Context, tablename string) (*TableSchema, error) {
 	var (
 		schema string
 		table  string
@@ -163,7 +232,7 @@ func (m dbMeta) DescribeTable(tablename string) (*TableSchema, error) {
 		return nil, fmt.Errorf("invalid table name: '%s'", tablename)
 	}
 
-	rows, err := m.Query(`SELECT column_name, column_default, is_nullable, data_type, udt_schema, udt_name
+	rows, err := m.QueryContext(ctx, `SELECT column_name, column_default, is_nullable, data_type, udt_schema, udt_name
                           FROM information_schema.columns
                           WHERE table_schema = $1 AND table_name = $2
                           ORDER BY ordinal_position`, schema, table)
@@ -12,31 +12,31 @@ import (
 	gomock "github.com/golang/mock/gomock"
 )
 
-// Mockquerier is a mock of querier interface
-type Mockquerier struct {
+// MockQuerier is a mock of Querier interface
+type MockQuerier struct {
 	ctrl     *gomock.Controller
-	recorder *MockquerierMockRecorder
+	recorder *MockQuerierMockRecorder
 }
 
-// MockquerierMockRecorder is the mock recorder for Mockquerier
-type MockquerierMockRecorder struct {
-	mock *Mockquerier
+// MockQuerierMockRecorder is the mock recorder for MockQuerier
+type MockQuerierMockRecorder struct {
+	mock *MockQuerier
 }
 
-// NewMockquerier creates a new mock instance
-func NewMockquerier(ctrl *gomock.Controller) *Mockquerier {
-	mock := &Mockquerier{ctrl: ctrl}
-	mock.recorder = &MockquerierMockRecorder{mock}
+// NewMockQuerier creates a new mock instance
+func NewMockQuerier(ctrl *gomock.Controller) *MockQuerier {
+	mock := &MockQuerier{ctrl: ctrl}
+	mock.recorder = &MockQuerierMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use
-func (m *Mockquerier) EXPECT() *MockquerierMockRecorder {
+func (m *MockQuerier) EXPECT() *MockQuerierMockRecorder {
 	return m.recorder
 }
 
 // PingContext mocks base method
-func (m *Mockquerier) PingContext(arg0 context.Context) error {
+func (m *MockQuerier) PingContext(arg0 context.Context) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "PingContext", arg0)
 	ret0, _ := ret[0].(error)
@@ -44,33 +44,68 @@ func (m *Mockquerier) PingContext(arg0 context.Context) error {
 }
 
 // PingContext indicates an expected call of PingContext
-func (mr *MockquerierMockRecorder) PingContext(arg0 interface{}) *gomock.Call {
+func (mr *MockQuerierMockRecorder) PingContext(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*Mockquerier)(nil).PingContext), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*MockQuerier)(nil).PingContext), arg0)
 }
 
-// Query mocks base method
-func (m *Mockquerier) Query(arg0 string, arg1 ...interface{}) (*sql.Rows, error) {
+// Conn mocks base method
+func (m *MockQuerier) Conn(arg0 context.Context) (*sql.Conn, error) {
 	m.ctrl.T.Helper()
-	varargs := []interface{}{arg0}
-	for _, a := range arg1 {
+	ret := m.ctrl.Call(m, "Conn", arg0)
+	ret0, _ := ret[0].(*sql.Conn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Conn indicates an expected call of Conn
+func (mr *MockQuerierMockRecorder) Conn(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Conn", reflect.TypeOf((*MockQuerier)(nil).Conn), arg0)
+}
+
+// ExecContext mocks base method
+func (m *MockQuerier) ExecContext(arg0 context.Context, arg1 string, arg2 ...interface{}) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext
+func (mr *MockQuerierMockRecorder) ExecContext(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*MockQuerier)(nil).ExecContext), varargs...)
+}
+
+// QueryContext mocks base method
+func (m *MockQuerier) QueryContext(arg0 context.Context, arg1 string, arg2 ...interface{}) (*sql.Rows, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
 		varargs = append(varargs, a)
 	}
-	ret := m.ctrl.Call(m, "Query", varargs...)
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
 	ret0, _ := ret[0].(*sql.Rows)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Query indicates an expected call of Query
-func (mr *MockquerierMockRecorder) Query(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+// QueryContext indicates an expected call of QueryContext
+func (mr *MockQuerierMockRecorder) QueryContext(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	varargs := append([]interface{}{arg0}, arg1...)
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*Mockquerier)(nil).Query), varargs...)
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*MockQuerier)(nil).QueryContext), varargs...)
 }
 
 // Stats mocks base method
-func (m *Mockquerier) Stats() sql.DBStats {
+func (m *MockQuerier) Stats() sql.DBStats {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Stats")
 	ret0, _ := ret[0].(sql.DBStats)
@@ -78,13 +113,13 @@ func (m *Mockquerier) Stats() sql.DBStats {
 }
 
 // Stats indicates an expected call of Stats
-func (mr *MockquerierMockRecorder) Stats() *gomock.Call {
+func (mr *MockQuerierMockRecorder) Stats() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*Mockquerier)(nil).Stats))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockQuerier)(nil).Stats))
 }
 
 // Close mocks base method
-func (m *Mockquerier) Close() error {
+func (m *MockQuerier) Close() error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
@@ -92,36 +127,36 @@ func (m *Mockquerier) Close() error {
 }
 
 // Close indicates an expected call of Close
-func (mr *MockquerierMockRecorder) Close() *gomock.Call {
+func (mr *MockQuerierMockRecorder) Close() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*Mockquerier)(nil).Close))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockQuerier)(nil).Close))
 }
 
-// MockmetaQuerier is a mock of metaQuerier interface
-type MockmetaQuerier struct {
+// MockMetaQuerier is a mock of MetaQuerier interface
+type MockMetaQuerier struct {
 	ctrl     *gomock.Controller
-	recorder *MockmetaQuerierMockRecorder
+	recorder *MockMetaQuerierMockRecorder
 }
 
-// MockmetaQuerierMockRecorder is the mock recorder for MockmetaQuerier
-type MockmetaQuerierMockRecorder struct {
-	mock *MockmetaQuerier
+// MockMetaQuerierMockRecorder is the mock recorder for MockMetaQuerier
+type MockMetaQuerierMockRecorder struct {
+	mock *MockMetaQuerier
 }
 
-// NewMockmetaQuerier creates a new mock instance
-func NewMockmetaQuerier(ctrl *gomock.Controller) *MockmetaQuerier {
-	mock := &MockmetaQuerier{ctrl: ctrl}
-	mock.recorder = &MockmetaQuerierMockRecorder{mock}
+// NewMockMetaQuerier creates a new mock instance
+func NewMockMetaQuerier(ctrl *gomock.Controller) *MockMetaQuerier {
+	mock := &MockMetaQuerier{ctrl: ctrl}
+	mock.recorder = &MockMetaQuerierMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use
-func (m *MockmetaQuerier) EXPECT() *MockmetaQuerierMockRecorder {
+func (m *MockMetaQuerier) EXPECT() *MockMetaQuerierMockRecorder {
 	return m.recorder
 }
 
 // PingContext mocks base method
-func (m *MockmetaQuerier) PingContext(arg0 context.Context) error {
+func (m *MockMetaQuerier) PingContext(arg0 context.Context) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "PingContext", arg0)
 	ret0, _ := ret[0].(error)
@@ -129,33 +164,68 @@ func (m *MockmetaQuerier) PingContext(arg0 context.Context) error {
 }
 
 // PingContext indicates an expected call of PingContext
-func (mr *MockmetaQuerierMockRecorder) PingContext(arg0 interface{}) *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) PingContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*MockMetaQuerier)(nil).PingContext), arg0)
+}
+
+// Conn mocks base method
+func (m *MockMetaQuerier) Conn(arg0 context.Context) (*sql.Conn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Conn", arg0)
+	ret0, _ := ret[0].(*sql.Conn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Conn indicates an expected call of Conn
+func (mr *MockMetaQuerierMockRecorder) Conn(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Conn", reflect.TypeOf((*MockMetaQuerier)(nil).Conn), arg0)
+}
+
+// ExecContext mocks base method
+func (m *MockMetaQuerier) ExecContext(arg0 context.Context, arg1 string, arg2 ...interface{}) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext
+func (mr *MockMetaQuerierMockRecorder) ExecContext(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingContext", reflect.TypeOf((*MockmetaQuerier)(nil).PingContext), arg0)
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*MockMetaQuerier)(nil).ExecContext), varargs...)
 }
 
-// Query mocks base method
-func (m *MockmetaQuerier) Query(arg0 string, arg1 ...interface{}) (*sql.Rows, error) {
+// QueryContext mocks base method
+func (m *MockMetaQuerier) QueryContext(arg0 context.Context, arg1 string, arg2 ...interface{}) (*sql.Rows, error) {
 	m.ctrl.T.Helper()
-	varargs := []interface{}{arg0}
-	for _, a := range arg1 {
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
 		varargs = append(varargs, a)
 	}
-	ret := m.ctrl.Call(m, "Query", varargs...)
+	ret := m.ctrl.Call(m, "QueryContext", varargs...)
 	ret0, _ := ret[0].(*sql.Rows)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Query indicates an expected call of Query
-func (mr *MockmetaQuerierMockRecorder) Query(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+// QueryContext indicates an expected call of QueryContext
+func (mr *MockMetaQuerierMockRecorder) QueryContext(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	varargs := append([]interface{}{arg0}, arg1...)
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockmetaQuerier)(nil).Query), varargs...)
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryContext", reflect.TypeOf((*MockMetaQuerier)(nil).QueryContext), varargs...)
 }
 
 // Stats mocks base method
-func (m *MockmetaQuerier) Stats() sql.DBStats {
+func (m *MockMetaQuerier) Stats() sql.DBStats {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Stats")
 	ret0, _ := ret[0].(sql.DBStats)
@@ -163,13 +233,13 @@ func (m *MockmetaQuerier) Stats() sql.DBStats {
 }
 
 // Stats indicates an expected call of Stats
-func (mr *MockmetaQuerierMockRecorder) Stats() *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) Stats() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockmetaQuerier)(nil).Stats))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockMetaQuerier)(nil).Stats))
 }
 
 // Close mocks base method
-func (m *MockmetaQuerier) Close() error {
+func (m *MockMetaQuerier) Close() error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
@@ -177,67 +247,67 @@ func (m *MockmetaQuerier) Close() error {
 }
 
 // Close indicates an expected call of Close
-func (mr *MockmetaQuerierMockRecorder) Close() *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) Close() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockmetaQuerier)(nil).Close))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMetaQuerier)(nil).Close))
 }
 
 // ListTables mocks base method
-func (m *MockmetaQuerier) ListTables() ([]string, error) {
+func (m *MockMetaQuerier) ListTables(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTables")
+	ret := m.ctrl.Call(m, "ListTables", arg0)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListTables indicates an expected call of ListTables
-func (mr *MockmetaQuerierMockRecorder) ListTables() *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) ListTables(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTables", reflect.TypeOf((*MockmetaQuerier)(nil).ListTables))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTables", reflect.TypeOf((*MockMetaQuerier)(nil).ListTables), arg0)
 }
 
 // ListTablesInSchema mocks base method
-func (m *MockmetaQuerier) ListTablesInSchema(arg0 string) ([]string, error) {
+func (m *MockMetaQuerier) ListTablesInSchema(arg0 context.Context, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTablesInSchema", arg0)
+	ret := m.ctrl.Call(m, "ListTablesInSchema", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListTablesInSchema indicates an expected call of ListTablesInSchema
-func (mr *MockmetaQuerierMockRecorder) ListTablesInSchema(arg0 interface{}) *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) ListTablesInSchema(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTablesInSchema", reflect.TypeOf((*MockmetaQuerier)(nil).ListTablesInSchema), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTablesInSchema", reflect.TypeOf((*MockMetaQuerier)(nil).ListTablesInSchema), arg0, arg1)
 }
 
 // ListSchemas mocks base method
-func (m *MockmetaQuerier) ListSchemas() ([]string, error) {
+func (m *MockMetaQuerier) ListSchemas(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListSchemas")
+	ret := m.ctrl.Call(m, "ListSchemas", arg0)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListSchemas indicates an expected call of ListSchemas
-func (mr *MockmetaQuerierMockRecorder) ListSchemas() *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) ListSchemas(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSchemas", reflect.TypeOf((*MockmetaQuerier)(nil).ListSchemas))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSchemas", reflect.TypeOf((*MockMetaQuerier)(nil).ListSchemas), arg0)
 }
 
 // DescribeTable mocks base method
-func (m *MockmetaQuerier) DescribeTable(arg0 string) (*TableSchema, error) {
+func (m *MockMetaQuerier) DescribeTable(arg0 context.Context, arg1 string) (*TableSchema, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DescribeTable", arg0)
+	ret := m.ctrl.Call(m, "DescribeTable", arg0, arg1)
 	ret0, _ := ret[0].(*TableSchema)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DescribeTable indicates an expected call of DescribeTable
-func (mr *MockmetaQuerierMockRecorder) DescribeTable(arg0 interface{}) *gomock.Call {
+func (mr *MockMetaQuerierMockRecorder) DescribeTable(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeTable", reflect.TypeOf((*MockmetaQuerier)(nil).DescribeTable), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeTable", reflect.TypeOf((*MockMetaQuerier)(nil).DescribeTable), arg0, arg1)
 }
@@ -0,0 +1,244 @@
+package dbman
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func init() {
+	RegisterBackend("mysql", Backend{
+		Connector: mysqlConnector,
+		Meta:      func(db Querier) MetaQuerier { return mysqlMeta{db} },
+		Dialect: Dialect{
+			IdentQuote:       '`',
+			BackslashEscapes: true,
+			HashComments:     true,
+			TimeLayout:       "2006-01-02 15:04:05.999999",
+		},
+		PasswordEnv: "MYSQL_PWD",
+	})
+}
+
+func mysqlConnector(conn *Connection) (driver.Connector, error) {
+	cfg, err := mysqlConfig(conn)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewConnector(cfg)
+}
+
+// mysqlConfig returns the driver's config for conn.
+// driver_opts are parsed as DSN parameters, on their own, so that the
+// connection's other fields never need to be escaped.
+func mysqlConfig(conn *Connection) (*mysql.Config, error) {
+	params := make(url.Values, len(conn.DriverOpts)+1)
+	// scan DATE and DATETIME columns as time.Time, rather than []byte
+	params.Set("parseTime", "true")
+	for k, v := range conn.DriverOpts {
+		params.Set(k, v)
+	}
+	if isTrue(conn.ReadOnly) {
+		// unknown parameters are set as session variables, set last so that
+		// driver_opts can't override it (including with its old name)
+		params.Del("tx_read_only")
+		params.Set("transaction_read_only", "1")
+	}
+
+	cfg, err := mysql.ParseDSN("/?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("invalid driver_opts: %w", err)
+	}
+	cfg.User = conn.Username
+	cfg.Passwd = conn.Password.Value
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+	cfg.DBName = conn.Database
+	return cfg, nil
+}
+
+// mysqlMeta treats MySQL databases as schemas.
+type mysqlMeta struct {
+	Querier
+}
+
+const mysqlSystemSchemas = `'information_schema', 'mysql', 'performance_schema', 'sys'`
+
+func (m mysqlMeta) ListTables(ctx context.Context) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT CONCAT(table_schema, '.', table_name) FROM information_schema.tables
+                          WHERE table_schema NOT IN (`+mysqlSystemSchemas+`)
+                          ORDER BY table_schema, table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+func (m mysqlMeta) ListTablesInSchema(ctx context.Context, schema string) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT table_name FROM information_schema.tables
+                          WHERE table_schema = ?
+                          ORDER BY table_name`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+func (m mysqlMeta) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT schema_name FROM information_schema.schemata
+                          WHERE schema_name NOT IN (`+mysqlSystemSchemas+`)
+                          ORDER BY schema_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, name)
+	}
+
+	return schemas, rows.Err()
+}
+
+// DescribeTable accepts either <table> or <database>.<table>, optionally backtick-quoted.
+// If no database is given, the connection's default database is used.
+func (m mysqlMeta) DescribeTable(ctx context.Context, tablename string) (*TableSchema, error) {
+	var (
+		schema string
+		table  string
+	)
+	parts, err := splitMySQLName(tablename)
+	if err != nil {
+		return nil, err
+	}
+	switch len(parts) {
+	case 2:
+		schema = parts[0]
+		table = parts[1]
+
+	case 1:
+		table = parts[0]
+
+	default:
+		return nil, fmt.Errorf("invalid table name: '%s'", tablename)
+	}
+
+	rows, err := m.QueryContext(ctx, `SELECT column_name, column_default, is_nullable, column_type, extra
+                          FROM information_schema.columns
+                          WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?
+                          ORDER BY ordinal_position`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := TableSchema{
+		Name: table,
+	}
+	for rows.Next() {
+		var col ColumnSchema
+
+		var (
+			defaultVal sql.NullString
+			nullable   yesOrNo
+			extra      sql.NullString
+		)
+		if err := rows.Scan(&col.Name, &defaultVal, &nullable, &col.Type, &extra); err != nil {
+			return nil, err
+		}
+
+		if defaultVal.Valid {
+			col.Attrs = append(col.Attrs, "DEFAULT "+defaultVal.String)
+		}
+
+		if nullable {
+			col.Attrs = append(col.Attrs, "NULL")
+		} else {
+			col.Attrs = append(col.Attrs, "NOT NULL")
+		}
+
+		if extra.String != "" {
+			col.Attrs = append(col.Attrs, strings.ToUpper(extra.String))
+		}
+
+		result.Columns = append(result.Columns, col)
+	}
+
+	return &result, rows.Err()
+}
+
+// splitMySQLName splits a dotted name into its parts, removing any backtick quoting.
+// Within a quoted part, a doubled backtick is an escaped backtick.
+func splitMySQLName(name string) ([]string, error) {
+	var (
+		parts  []string
+		sb     strings.Builder
+		quoted bool
+	)
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '`' && quoted && i+1 < len(name) && name[i+1] == '`':
+			sb.WriteByte('`')
+			i++
+
+		case c == '`':
+			quoted = !quoted
+
+		case c == '.' && !quoted:
+			parts = append(parts, sb.String())
+			sb.Reset()
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	if quoted {
+		return nil, errors.New("unterminated quoted name")
+	}
+	parts = append(parts, sb.String())
+
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid table name: '%s'", name)
+		}
+	}
+	return parts, nil
+}
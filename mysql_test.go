@@ -0,0 +1,148 @@
+package dbman
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_splitMySQLName(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{name: "table", in: "users", want: []string{"users"}},
+		{name: "schema and table", in: "app.users", want: []string{"app", "users"}},
+		{name: "quoted", in: "`my app`.`user.events`", want: []string{"my app", "user.events"}},
+		{name: "escaped backtick", in: "`a``b`", want: []string{"a`b"}},
+		{name: "unterminated", in: "`app.users", wantErr: true},
+		{name: "empty part", in: "app.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitMySQLName(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, but got: %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_mysqlConfig(t *testing.T) {
+	cfg, err := mysqlConfig(&Connection{
+		Host:       "db.example.com",
+		Port:       3306,
+		Database:   "what?db",
+		Username:   "me@work",
+		Password:   Secret{Value: "p/ss@word:?"},
+		DriverOpts: map[string]string{"tls": "skip-verify", "sql_mode": "ANSI"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.User != "me@work" || cfg.Passwd != "p/ss@word:?" || cfg.DBName != "what?db" {
+		t.Errorf("expected user, password, and database as given, but got: %q, %q, %q", cfg.User, cfg.Passwd, cfg.DBName)
+	}
+	if cfg.Net != "tcp" || cfg.Addr != "db.example.com:3306" {
+		t.Errorf("expected tcp to db.example.com:3306, but got: %s(%s)", cfg.Net, cfg.Addr)
+	}
+	if !cfg.ParseTime || cfg.TLSConfig != "skip-verify" {
+		t.Errorf("expected driver_opts to be parsed as DSN parameters, but got: parseTime=%v tls=%q", cfg.ParseTime, cfg.TLSConfig)
+	}
+	if diff := cmp.Diff(map[string]string{"sql_mode": "ANSI"}, cfg.Params); diff != "" {
+		t.Errorf("unexpected session variables (-want +got):\n%s", diff)
+	}
+
+	cfg, err = mysqlConfig(&Connection{
+		Host:       "db.example.com",
+		ReadOnly:   boolPtr(true),
+		DriverOpts: map[string]string{"transaction_read_only": "0", "tx_read_only": "0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"transaction_read_only": "1"}, cfg.Params); diff != "" {
+		t.Errorf("expected driver_opts not to override read_only (-want +got):\n%s", diff)
+	}
+}
+
+func Test_mysqlMeta(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	meta := mysqlMeta{db}
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT CONCAT\\(table_schema, '.', table_name\\) FROM information_schema.tables").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("app.orders").AddRow("app.users"))
+	tables, err := meta.ListTables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"app.orders", "app.users"}, tables); diff != "" {
+		t.Errorf("ListTables (-want +got):\n%s", diff)
+	}
+
+	mock.ExpectQuery("SELECT table_name FROM information_schema.tables").
+		WithArgs("app").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("orders"))
+	tables, err = meta.ListTablesInSchema(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"orders"}, tables); diff != "" {
+		t.Errorf("ListTablesInSchema (-want +got):\n%s", diff)
+	}
+
+	mock.ExpectQuery("SELECT schema_name FROM information_schema.schemata").
+		WillReturnRows(sqlmock.NewRows([]string{"schema_name"}).AddRow("app").AddRow("my db"))
+	schemas, err := meta.ListSchemas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"app", "my db"}, schemas); diff != "" {
+		t.Errorf("ListSchemas (-want +got):\n%s", diff)
+	}
+
+	mock.ExpectQuery("SELECT column_name, column_default, is_nullable, column_type, extra").
+		WithArgs("my db", "user.events").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "column_default", "is_nullable", "column_type", "extra"}).
+			AddRow("id", nil, "NO", "bigint unsigned", "auto_increment").
+			AddRow("name", "'anon'", "YES", "varchar(64)", ""))
+	schema, err := meta.DescribeTable(ctx, "`my db`.`user.events`")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &TableSchema{
+		Name: "user.events",
+		Columns: []ColumnSchema{
+			{Name: "id", Type: "bigint unsigned", Attrs: []string{"NOT NULL", "AUTO_INCREMENT"}},
+			{Name: "name", Type: "varchar(64)", Attrs: []string{"DEFAULT 'anon'", "NULL"}},
+		},
+	}
+	if diff := cmp.Diff(expect, schema); diff != "" {
+		t.Errorf("DescribeTable (-want +got):\n%s", diff)
+	}
+
+	mock.ExpectQuery("SELECT column_name").
+		WithArgs("", "users").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "column_default", "is_nullable", "column_type", "extra"}))
+	if _, err := meta.DescribeTable(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,219 @@
+package dbman
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// postgresSettings returns the libpq connection settings for conn: those in its
+// dsn, overridden by its other fields, and then its driver_opts.
+func postgresSettings(conn *Connection) (map[string]string, error) {
+	settings, err := parseConninfo(conn.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+	set("host", conn.Host)
+	if conn.Port != 0 {
+		set("port", strconv.Itoa(conn.Port))
+	}
+	set("dbname", conn.Database)
+	set("user", conn.Username)
+	set("password", conn.Password.Value)
+	if _, ok := settings["sslmode"]; !ok {
+		settings["sslmode"] = "require"
+	}
+	for k, v := range conn.DriverOpts {
+		settings[k] = v
+	}
+
+	if isTrue(conn.ReadOnly) {
+		options := "-c default_transaction_read_only=on"
+		if settings["options"] != "" {
+			options = settings["options"] + " " + options
+		}
+		settings["options"] = options
+	}
+	return settings, nil
+}
+
+// parseConninfo parses a libpq connection string, either a postgres:// URL, or
+// space separated key=value pairs, where values may be single quoted.
+func parseConninfo(dsn string) (map[string]string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		dsn, err = pq.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	settings := make(map[string]string)
+	r := strings.NewReader(dsn)
+	for {
+		key, err := readConninfoWord(r, true)
+		if err == io.EOF {
+			return settings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, errors.New("missing key before '='")
+		}
+
+		value, err := readConninfoWord(r, false)
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing value for '%s'", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+}
+
+// readConninfoWord reads the next key (up to the '='), or value (up to the next
+// space) from r, skipping leading spaces.
+func readConninfoWord(r *strings.Reader, isKey bool) (string, error) {
+	var (
+		sb      strings.Builder
+		quoted  bool
+		started bool
+	)
+	for {
+		ch, _, err := r.ReadRune()
+		if err == io.EOF {
+			switch {
+			case quoted:
+				return "", errors.New("unterminated quoted string")
+			case isKey && started:
+				return "", fmt.Errorf("missing '=' after '%s'", sb.String())
+			case !started:
+				return "", io.EOF
+			default:
+				return sb.String(), nil
+			}
+		}
+
+		switch {
+		case ch == ' ' && !quoted:
+			if started {
+				if isKey {
+					// spaces are allowed around the '='
+					continue
+				}
+				return sb.String(), nil
+			}
+
+		case ch == '=' && isKey:
+			return sb.String(), nil
+
+		case ch == '\'' && !isKey:
+			quoted = !quoted
+			started = true
+
+		case ch == '\\' && !isKey:
+			if ch, _, err = r.ReadRune(); err != nil {
+				return "", errors.New("missing character after '\\'")
+			}
+			sb.WriteRune(ch)
+			started = true
+
+		default:
+			sb.WriteRune(ch)
+			started = true
+		}
+	}
+}
+
+var conninfoEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// formatConninfo returns settings as a libpq connection string, in order of key,
+// with every value quoted.
+func formatConninfo(settings map[string]string) string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "='" + conninfoEscaper.Replace(settings[k]) + "'"
+	}
+	return strings.Join(pairs, " ")
+}
+
+func validateTargetSessionAttrs(attrs string) error {
+	switch attrs {
+	case "any", "read-write", "read-only":
+		return nil
+	default:
+		return errors.New("must be one of: any, read-write, read-only")
+	}
+}
+
+// targetSessionConnector checks each new connection against target_session_attrs,
+// which lib/pq doesn't support itself.
+type targetSessionConnector struct {
+	driver.Connector
+	attrs string
+}
+
+func (c targetSessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil || c.attrs == "any" {
+		return conn, err
+	}
+
+	readOnly, err := isReadOnlySession(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("target_session_attrs: %w", err)
+	}
+	if readOnly == (c.attrs == "read-write") {
+		conn.Close()
+		return nil, fmt.Errorf("target_session_attrs: session is not %s", c.attrs)
+	}
+	return conn, nil
+}
+
+func isReadOnlySession(ctx context.Context, conn driver.Conn) (bool, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return false, errors.New("connection does not support queries")
+	}
+
+	rows, err := queryer.QueryContext(ctx, "SHOW transaction_read_only", nil)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, len(rows.Columns()))
+	if err := rows.Next(values); err != nil {
+		return false, err
+	}
+	switch v := values[0].(type) {
+	case string:
+		return v == "on", nil
+	case []byte:
+		return string(v) == "on", nil
+	default:
+		return false, fmt.Errorf("unexpected transaction_read_only: %v", v)
+	}
+}
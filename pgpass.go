@@ -0,0 +1,119 @@
+package dbman
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// pgpassFile returns the path of libpq's password file, i.e. PGPASSFILE, or ~/.pgpass.
+func pgpassFile() (string, error) {
+	if path := os.Getenv("PGPASSFILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pgpass"), nil
+}
+
+// lookupPgpass returns the password for conn from the first matching line of the
+// password file, which has lines of the form:
+//
+//	hostname:port:database:username:password
+//
+// Any of the first four fields may be *, to match anything.
+// Like libpq, the file is refused if anyone other than its owner can read it.
+func lookupPgpass(conn *Connection) (password string, ok bool, err error) {
+	path, err := pgpassFile()
+	if err != nil {
+		return "", false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer f.Close()
+
+	if runtime.GOOS != "windows" {
+		info, err := f.Stat()
+		if err != nil {
+			return "", false, err
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return "", false, fmt.Errorf("password file %s has group or world access; permissions should be u=rw (0600) or less", path)
+		}
+	}
+
+	want := []string{conn.Host, strconv.Itoa(conn.Port), conn.Database, conn.Username}
+	if conn.DSN != "" {
+		// anything not set by conn's fields may be set by its dsn
+		settings, err := parseConninfo(conn.DSN)
+		if err != nil {
+			return "", false, err
+		}
+		for i, key := range []string{"host", "port", "dbname", "user"} {
+			if (want[i] == "" || want[i] == "0") && settings[key] != "" {
+				want[i] = settings[key]
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := splitPgpassLine(line)
+		if len(fields) < 5 {
+			continue
+		}
+		if matchPgpass(fields[:4], want) {
+			return fields[4], true, nil
+		}
+	}
+	return "", false, scanner.Err()
+}
+
+// splitPgpassLine splits line on unescaped colons, and unescapes \: and \\.
+func splitPgpassLine(line string) []string {
+	var (
+		fields []string
+		sb     strings.Builder
+	)
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case ch == '\\' && i+1 < len(line):
+			i++
+			sb.WriteByte(line[i])
+
+		case ch == ':' && len(fields) < 4:
+			fields = append(fields, sb.String())
+			sb.Reset()
+
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return append(fields, sb.String())
+}
+
+func matchPgpass(fields, want []string) bool {
+	for i, field := range fields {
+		if field != "*" && field != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package dbman
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultSysconfDir is where libpq looks for the system wide service file, if PGSYSCONFDIR isn't set.
+const defaultSysconfDir = "/etc/postgresql-common"
+
+// pgServiceFiles returns the paths of libpq's connection service files, in the
+// order they're searched, i.e. PGSERVICEFILE (or ~/.pg_service.conf), then
+// pg_service.conf in PGSYSCONFDIR.
+func pgServiceFiles() []string {
+	var files []string
+	if path := os.Getenv("PGSERVICEFILE"); path != "" {
+		files = append(files, path)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".pg_service.conf"))
+	}
+
+	sysconfDir := os.Getenv("PGSYSCONFDIR")
+	if sysconfDir == "" {
+		sysconfDir = defaultSysconfDir
+	}
+	return append(files, filepath.Join(sysconfDir, "pg_service.conf"))
+}
+
+// lookupPgService returns the settings of the named service, from the first
+// service file that has it. Service files are INI files, with a section per service:
+//
+//	[prod]
+//	host=db.example.com
+//	dbname=app
+func lookupPgService(name string) (map[string]string, error) {
+	for _, path := range pgServiceFiles() {
+		settings, ok, err := readPgService(path, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return settings, nil
+		}
+	}
+	return nil, fmt.Errorf("service '%s' not found in: %s", name, strings.Join(pgServiceFiles(), ", "))
+}
+
+func readPgService(path, name string) (settings map[string]string, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+
+		case line[0] == '[':
+			if ok {
+				// the end of the service
+				return settings, true, nil
+			}
+			if strings.TrimSuffix(line[1:], "]") == name {
+				ok = true
+				settings = make(map[string]string)
+			}
+
+		case ok:
+			eq := strings.IndexByte(line, '=')
+			if eq < 0 {
+				return nil, false, fmt.Errorf("%s:%d: syntax error in service file", path, lineNum)
+			}
+			settings[strings.TrimSpace(line[:eq])] = strings.TrimSpace(line[eq+1:])
+		}
+	}
+	return settings, ok, scanner.Err()
+}
+
+// applyService fills in any of c's host, port, database, username, and sslmode
+// that aren't set, from its service.
+func (c *Connection) applyService() error {
+	if c.Service == "" {
+		return nil
+	}
+
+	settings, err := lookupPgService(c.Service)
+	if err != nil {
+		return err
+	}
+
+	if c.Host == "" {
+		c.Host = settings["host"]
+	}
+	if c.Port == 0 && settings["port"] != "" {
+		c.Port, err = strconv.Atoi(settings["port"])
+		if err != nil {
+			return fmt.Errorf("service '%s': invalid port: %w", c.Service, err)
+		}
+	}
+	if c.Database == "" {
+		c.Database = settings["dbname"]
+	}
+	if c.Username == "" {
+		c.Username = settings["user"]
+	}
+	if sslmode, ok := settings["sslmode"]; ok {
+		if _, ok := c.DriverOpts["sslmode"]; !ok {
+			if c.DriverOpts == nil {
+				c.DriverOpts = make(map[string]string)
+			}
+			c.DriverOpts["sslmode"] = sslmode
+		}
+	}
+	return nil
+}
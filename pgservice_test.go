@@ -0,0 +1,111 @@
+package dbman
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Connection_applyService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbman-pgservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	userFile := filepath.Join(dir, "pg_service.conf")
+	err = ioutil.WriteFile(userFile, []byte(`
+# team services
+[dev]
+host=localhost
+port=5433
+dbname=app
+
+[prod]
+host = db.example.com
+port = 5432
+dbname = app
+user = reporting
+sslmode = verify-full
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sysconfDir := filepath.Join(dir, "etc")
+	if err := os.Mkdir(sysconfDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(sysconfDir, "pg_service.conf"), []byte("[shared]\nhost=shared.example.com\nport=5432\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer setenv(t, "PGSERVICEFILE", userFile)()
+	defer setenv(t, "PGSYSCONFDIR", sysconfDir)()
+
+	tests := []struct {
+		name      string
+		conn      Connection
+		expect    Connection
+		expectErr bool
+	}{
+		{
+			name:   "no service",
+			conn:   Connection{Host: "localhost"},
+			expect: Connection{Host: "localhost"},
+		},
+		{
+			name: "service",
+			conn: Connection{Service: "prod"},
+			expect: Connection{
+				Service:    "prod",
+				Host:       "db.example.com",
+				Port:       5432,
+				Database:   "app",
+				Username:   "reporting",
+				DriverOpts: map[string]string{"sslmode": "verify-full"},
+			},
+		},
+		{
+			name: "config overrides service",
+			conn: Connection{Service: "prod", Database: "other", DriverOpts: map[string]string{"sslmode": "disable"}},
+			expect: Connection{
+				Service:    "prod",
+				Host:       "db.example.com",
+				Port:       5432,
+				Database:   "other",
+				Username:   "reporting",
+				DriverOpts: map[string]string{"sslmode": "disable"},
+			},
+		},
+		{
+			name:   "system service file",
+			conn:   Connection{Service: "shared", Database: "app", Username: "me"},
+			expect: Connection{Service: "shared", Host: "shared.example.com", Port: 5432, Database: "app", Username: "me"},
+		},
+		{
+			name:      "unknown service",
+			conn:      Connection{Service: "nope"},
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conn.applyService()
+			if tt.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expect, tt.conn); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package dbman
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrReadOnly is returned when refusing to run a statement that writes on a read only connection.
+var ErrReadOnly = errors.New("connection is read only")
+
+// checkWrite refuses to run stmt if it writes, and the current connection is read only,
+// or writes must be confirmed, and weren't.
+func (d *DBMan) checkWrite(stmt string) error {
+	s := d.current
+	if (!s.readOnly && !s.confirmWrites) || !isWrite(stmt) {
+		return nil
+	}
+
+	keyword := leadingKeyword(stmt)
+	if s.readOnly {
+		return fmt.Errorf("%w, refusing to run %s", ErrReadOnly, keyword)
+	}
+
+	if s.prompter == nil {
+		return fmt.Errorf("writes to '%s' must be confirmed, but there's no way to prompt", d.currentName)
+	}
+	question := fmt.Sprintf("run %s on '%s'? [y/N] ", keyword, d.currentName)
+	answers, err := s.prompter("", "", []string{question}, []bool{true})
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answers[0])) {
+	case "y", "yes":
+		return nil
+
+	default:
+		return fmt.Errorf("%s not confirmed, it was not run", keyword)
+	}
+}
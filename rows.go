@@ -0,0 +1,236 @@
+package dbman
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Rows streams the results of a query, one row at a time.
+// Columns are known before the first call to Next.
+type Rows struct {
+	Columns     []string
+	ColumnTypes []string // database type names, e.g. "VARCHAR", as reported by the driver
+
+	rows     *sql.Rows
+	cancel   context.CancelFunc
+	scanners []interface{}
+	row      []interface{}
+	maxRows  int
+	count    int
+	more     bool
+	capped   bool // stopped at maxRows, and closed
+	err      error
+}
+
+// newRows takes ownership of rows. cancel is called when the Rows are closed.
+func newRows(rows *sql.Rows, maxRows int, cancel context.CancelFunc) (*Rows, error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		cancel()
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		defer cancel()
+		defer rows.Close()
+		// some drivers (e.g. sqlite) don't execute the statement until the first call to Next
+		for rows.Next() {
+		}
+		return nil, rows.Err()
+	}
+
+	r := &Rows{
+		Columns:     make([]string, len(columns)),
+		ColumnTypes: make([]string, len(columns)),
+		rows:        rows,
+		cancel:      cancel,
+		scanners:    make([]interface{}, len(columns)),
+		maxRows:     maxRows,
+	}
+	for i, col := range columns {
+		r.Columns[i] = col.Name()
+		r.ColumnTypes[i] = col.DatabaseTypeName()
+		r.scanners[i] = newScanner(col)
+	}
+	return r, nil
+}
+
+// Next prepares the next row to be read with Row.
+// It returns false when there are no more rows, an error occurred, or the maximum
+// number of rows has been reached.
+func (r *Rows) Next() bool {
+	if r.err != nil || r.capped {
+		return false
+	}
+
+	if r.maxRows > 0 && r.count >= r.maxRows {
+		if r.more = r.rows.Next(); !r.more {
+			r.err = r.rows.Err()
+		}
+		r.capped = true
+		// the query isn't cancelled, since some drivers (e.g. lib/pq) close the
+		// connection when it is, losing the session (e.g. an open transaction)
+		r.rows.Close()
+		return false
+	}
+
+	if !r.rows.Next() {
+		return false
+	}
+
+	if err := r.rows.Scan(r.scanners...); err != nil {
+		r.err = err
+		return false
+	}
+
+	r.row = make([]interface{}, len(r.scanners))
+	for i, val := range r.scanners {
+		switch val := val.(type) {
+		case nil:
+			r.row[i] = nullValue{}
+		case *anyVal:
+			r.row[i] = val.V
+		default:
+			r.row[i] = reflect.Indirect(reflect.ValueOf(val)).Interface()
+		}
+	}
+	r.count++
+	return true
+}
+
+// Row returns the current row. The returned slice is not reused by subsequent calls to Next.
+func (r *Rows) Row() []interface{} {
+	return r.row
+}
+
+// Batch reads up to n of the next rows. An empty batch means there aren't
+// any more rows, or an error occurred (see Err).
+func (r *Rows) Batch(n int) [][]interface{} {
+	var batch [][]interface{}
+	for len(batch) < n && r.Next() {
+		batch = append(batch, r.Row())
+	}
+	return batch
+}
+
+// ReadAll reads the remaining rows into result, and sets its More.
+func (r *Rows) ReadAll(result *QueryResult) error {
+	for r.Next() {
+		result.Rows = append(result.Rows, r.Row())
+	}
+	result.More = r.More()
+	return r.Err()
+}
+
+// More reports if Next stopped because the maximum number of rows was reached,
+// while more rows were still available.
+func (r *Rows) More() bool {
+	return r.more
+}
+
+func (r *Rows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.capped {
+		// closing early isn't an error
+		return nil
+	}
+	return r.rows.Err()
+}
+
+// Close stops reading rows. The query is only cancelled once they're closed,
+// since some drivers (e.g. lib/pq) close the connection if it's cancelled first,
+// losing the session (e.g. an open transaction). Those drivers read any remaining
+// rows when closed, so an interrupt, or timeout, should cancel the query's context.
+func (r *Rows) Close() error {
+	err := r.rows.Close()
+	r.cancel()
+	return err
+}
+
+var (
+	rawBytesType  = reflect.TypeOf(sql.RawBytes(nil))
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+func newScanner(col *sql.ColumnType) interface{} {
+	typeName := strings.ToUpper(col.DatabaseTypeName())
+	switch typeName {
+	case "CHARACTER", "CHAR", "CHARACTER VARYING", "VARCHAR", "NVARCHAR", "TEXT",
+		"TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "ENUM", "SET":
+		return new(nullString)
+
+	case "BOOL", "BOOLEAN":
+		return new(nullBool)
+
+	case "BIGINT", "INT8", "BIGSERIAL", "SERIAL8":
+		return new(nullInt64)
+
+	case "INTEGER", "INT", "INT4", "SERIAL", "SERIAL4", "MEDIUMINT":
+		return new(nullInt32)
+
+	case "SMALLINT", "INT2", "SMALLSERIAL", "SERIAL2", "TINYINT":
+		return new(nullInt16)
+
+	case "DOUBLE", "FLOAT8":
+		return new(nullFloat64)
+
+	case "REAL", "FLOAT4", "FLOAT":
+		return new(nullFloat32)
+
+	case "NUMERIC", "DECIMAL":
+		return new(nullDecimal)
+
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATE", "DATETIME":
+		return new(nullTime)
+
+	case "TIME", "TIMETZ":
+		if col.ScanType() != timeType {
+			// e.g. mysql's, which are durations (up to 838:59:59), rather than times of day
+			return new(nullString)
+		}
+		return new(nullTime)
+
+	case "INTERVAL":
+		return new(nullInterval)
+
+	case "UUID":
+		return new(uuidVal)
+
+	case "JSON", "JSONB":
+		return new(nullJSON)
+
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY":
+		return new(nullBytes)
+
+	case "INET", "CIDR":
+		return new(nullInet)
+
+	case "INT4RANGE", "INT8RANGE", "NUMRANGE", "TSRANGE", "TSTZRANGE", "DATERANGE":
+		return new(nullRange)
+
+	case "ARRAY":
+		return new(nullArray)
+	}
+
+	switch {
+	case strings.HasPrefix(typeName, "_"):
+		// lib/pq names array types after their element type, e.g. _INT4
+		return &nullArray{elemType: typeName[1:]}
+
+	case col.ScanType() == nil, col.ScanType() == interfaceType, col.ScanType() == rawBytesType:
+		// e.g. sqlite expressions, whose type isn't known until they're evaluated,
+		// or types the driver doesn't know about.
+		// RawBytes is only valid until the next call to Next, so a copy is taken instead.
+		return new(anyVal)
+
+	default:
+		return reflect.New(col.ScanType()).Interface()
+	}
+}
@@ -0,0 +1,164 @@
+package dbman
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// StatementResult is the outcome of running a single statement of a script.
+type StatementResult struct {
+	QueryResult
+	Statement string
+	Notices   []string // messages sent by the server while the statement ran, e.g. postgres' RAISE NOTICE
+	Err       error
+	Duration  time.Duration // for statements that return rows, until the rows were ready to be read
+}
+
+// StatementFunc is called by QueryScript with the result of each statement that was run.
+// If the statement returned rows, they're streamed with rows (rather than being
+// in result), which is closed when StatementFunc returns. Otherwise, rows is nil.
+// Returning an error stops the script.
+type StatementFunc func(result *StatementResult, rows *Rows) error
+
+// QueryScript splits script into statements (see Dialect.SplitStatements), runs each of them
+// in order, and calls fn with each of their results, as they're run.
+// Running stops after the first statement that fails, whose error is set in its result,
+// and returned, unless fn returns an error of its own.
+// At most Config.MaxRows rows are returned per statement.
+//
+// Bind variables in the statements (:name, or $1) are resolved with vars,
+// and passed to the driver as parameters. If vars is nil, statements are run as is.
+func (d *DBMan) QueryScript(ctx context.Context, script string, vars VarLookup, fn StatementFunc) error {
+	if d.current == nil {
+		return errors.New("an active connection is required")
+	}
+
+	placeholder := d.current.placeholder
+	if placeholder == nil {
+		placeholder = questionPlaceholder
+	}
+
+	dialect := d.Dialect()
+	for _, stmt := range dialect.SplitStatements(script) {
+		var (
+			result StatementResult
+			rows   *Rows
+		)
+		if bound, args, err := dialect.bindVars(stmt, vars, placeholder); err != nil {
+			result = StatementResult{QueryResult: QueryResult{RowsAffected: -1}, Err: err}
+		} else {
+			result, rows = d.runStatement(ctx, bound, args)
+		}
+		result.Statement = stmt
+
+		if err := d.handleStatement(fn, &result, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleStatement calls fn with a statement's result, and returns the error that
+// should stop the script, if any.
+func (d *DBMan) handleStatement(fn StatementFunc, result *StatementResult, rows *Rows) error {
+	if rows != nil {
+		defer rows.Close()
+	}
+
+	if err := fn(result, rows); err != nil {
+		return err
+	}
+	if result.Err != nil {
+		return result.Err
+	}
+	if rows != nil {
+		return rows.Err()
+	}
+	return nil
+}
+
+// runStatement runs stmt. If it returns rows, they're returned to be streamed,
+// and must be closed.
+func (d *DBMan) runStatement(ctx context.Context, stmt string, args []interface{}) (StatementResult, *Rows) {
+	result := StatementResult{
+		QueryResult: QueryResult{
+			RowsAffected: -1,
+		},
+		Statement: stmt,
+	}
+
+	if err := d.checkWrite(stmt); err != nil {
+		result.Err = err
+		return result, nil
+	}
+
+	// discard anything left over from e.g. ListTables
+	d.notices.drain()
+
+	var rows *Rows
+	start := time.Now()
+	if isExec(stmt) {
+		result.Err = d.execStatement(ctx, stmt, args, &result)
+	} else {
+		rows, result.Err = d.queryRows(ctx, stmt, args...)
+	}
+	result.Duration = time.Since(start)
+	result.Notices = d.notices.drain()
+
+	if result.Err == nil {
+		d.current.trackTransaction(stmt)
+		if rows != nil {
+			result.Columns = rows.Columns
+			result.ColumnTypes = rows.ColumnTypes
+		} else {
+			result.Command = commandTag(stmt, result.RowsAffected)
+		}
+	}
+
+	return result, rows
+}
+
+func (d *DBMan) execStatement(ctx context.Context, stmt string, args []interface{}, result *StatementResult) error {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
+
+	conn, err := d.pinnedConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := conn.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		d.dropBadConn(err)
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err == nil {
+		result.RowsAffected = affected
+	}
+	return nil
+}
+
+// noticeLog collects the messages sent by the server, while a statement runs.
+type noticeLog struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *noticeLog) add(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *noticeLog) drain() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	msgs := l.msgs
+	l.msgs = nil
+	return msgs
+}
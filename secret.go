@@ -0,0 +1,251 @@
+package dbman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Secret is a password, or passphrase.
+// In a config file, it's either the secret itself, as a string, or an object
+// naming the SecretProvider to get it from, and the provider's settings, e.g.
+//
+//	"password": {"from": "command", "cmd": ["pass", "db/prod"]}
+//
+// Secrets from a provider are only resolved when they're needed, e.g. when connecting.
+type Secret struct {
+	Value string `json:"-"` // the secret itself, if given directly
+
+	From       string            `json:"from,omitempty"`       // the name of a SecretProvider
+	Cmd        []string          `json:"cmd,omitempty"`        // command: the command (and arguments) that prints the secret
+	Name       string            `json:"name,omitempty"`       // env: the environment variable containing the secret
+	Path       string            `json:"path,omitempty"`       // file: the file containing the secret
+	Attributes map[string]string `json:"attributes,omitempty"` // secret_service: the attributes identifying the secret
+}
+
+// SecretProvider describes how to get a Secret from somewhere other than the config file.
+// Providers are registered by name with RegisterSecretProvider, and selected by a Secret's From.
+type SecretProvider struct {
+	// Resolve returns the secret described by s.
+	// conn is the connection the secret is for, or nil if it's for a tunnel.
+	Resolve func(s *Secret, conn *Connection) (string, error)
+
+	// Validate checks s for provider specific requirements. (optional)
+	Validate func(prefix string, s *Secret) error
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = make(map[string]SecretProvider)
+)
+
+func init() {
+	RegisterSecretProvider("command", SecretProvider{Resolve: commandSecret, Validate: requireSecretField("cmd", func(s *Secret) bool { return len(s.Cmd) != 0 })})
+	RegisterSecretProvider("env", SecretProvider{Resolve: envSecret, Validate: requireSecretField("name", func(s *Secret) bool { return s.Name != "" })})
+	RegisterSecretProvider("file", SecretProvider{Resolve: fileSecret, Validate: requireSecretField("path", func(s *Secret) bool { return s.Path != "" })})
+	RegisterSecretProvider("pgpass", SecretProvider{Resolve: pgpassSecret})
+	RegisterSecretProvider("secret_service", SecretProvider{Resolve: secretServiceSecret, Validate: requireSecretField("attributes", func(s *Secret) bool { return len(s.Attributes) != 0 })})
+}
+
+// RegisterSecretProvider makes a provider available to secrets whose from is name.
+// Like RegisterBackend, it panics if called twice with the same name, or if the
+// provider is missing Resolve.
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+
+	if provider.Resolve == nil {
+		panic("dbman: RegisterSecretProvider " + name + " is missing Resolve")
+	}
+	if _, dup := secretProviders[name]; dup {
+		panic("dbman: RegisterSecretProvider called twice for provider " + name)
+	}
+	secretProviders[name] = provider
+}
+
+// SecretProviders returns a sorted list of the names of the registered secret providers.
+func SecretProviders() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+
+	names := make([]string, 0, len(secretProviders))
+	for name := range secretProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupSecretProvider(name string) (SecretProvider, bool) {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+
+	provider, ok := secretProviders[name]
+	return provider, ok
+}
+
+// Resolve returns the secret, from its provider if it has one.
+// conn is the connection the secret is for, or nil if it's for a tunnel.
+// An empty secret resolves to "", e.g. so that it can be prompted for.
+func (s *Secret) Resolve(conn *Connection) (string, error) {
+	if s.From == "" {
+		return s.Value, nil
+	}
+
+	provider, ok := lookupSecretProvider(s.From)
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider '%s'", s.From)
+	}
+	value, err := provider.Resolve(s, conn)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", s.From, err)
+	}
+	return value, nil
+}
+
+func (s *Secret) validate(prefix string) error {
+	if s.From == "" {
+		return nil
+	}
+
+	provider, ok := lookupSecretProvider(s.From)
+	if !ok {
+		return errors.New(prefix + ".from: must be one of: " + strings.Join(SecretProviders(), ", "))
+	}
+	if provider.Validate != nil {
+		return provider.Validate(prefix, s)
+	}
+	return nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s.From == "" {
+		return json.Marshal(s.Value)
+	}
+	type secret Secret
+	return json.Marshal(secret(s))
+}
+
+func (s *Secret) UnmarshalJSON(buf []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(buf), []byte(`"`)) {
+		*s = Secret{}
+		return json.Unmarshal(buf, &s.Value)
+	}
+
+	type secret Secret
+	var v secret
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return err
+	}
+	*s = Secret(v)
+	return nil
+}
+
+func requireSecretField(field string, isSet func(s *Secret) bool) func(prefix string, s *Secret) error {
+	return func(prefix string, s *Secret) error {
+		if !isSet(s) {
+			return errors.New(prefix + "." + field + ": required")
+		}
+		return nil
+	}
+}
+
+// commandSecret runs s.Cmd, and returns what it prints, without a trailing newline.
+func commandSecret(s *Secret, _ *Connection) (string, error) {
+	return runSecretCommand(s.Cmd[0], s.Cmd[1:]...)
+}
+
+func runSecretCommand(name string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+func envSecret(s *Secret, _ *Connection) (string, error) {
+	value, ok := os.LookupEnv(s.Name)
+	if !ok {
+		return "", fmt.Errorf("%s is not set", s.Name)
+	}
+	return value, nil
+}
+
+// fileSecret returns the contents of s.Path, without a trailing newline.
+func fileSecret(s *Secret, _ *Connection) (string, error) {
+	path, err := expandHome(s.Path)
+	if err != nil {
+		return "", err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(buf), "\r\n"), nil
+}
+
+// pgpassSecret looks up conn's password in the password file, i.e. ~/.pgpass, or PGPASSFILE.
+func pgpassSecret(_ *Secret, conn *Connection) (string, error) {
+	if conn == nil {
+		return "", errors.New("only database connections can use the password file")
+	}
+
+	password, ok, err := lookupPgpass(conn)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("no matching entry in the password file")
+	}
+	return password, nil
+}
+
+// secretServiceSecret looks up the secret with s.Attributes in the freedesktop
+// Secret Service (e.g. GNOME Keyring, or KWallet), with libsecret's secret-tool.
+func secretServiceSecret(s *Secret, _ *Connection) (string, error) {
+	keys := make([]string, 0, len(s.Attributes))
+	for key := range s.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := []string{"lookup"}
+	for _, key := range keys {
+		args = append(args, key, s.Attributes[key])
+	}
+
+	value, err := runSecretCommand("secret-tool", args...)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", errors.New("no matching secret")
+	}
+	return value, nil
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return home + path[1:], nil
+}
@@ -0,0 +1,295 @@
+package dbman
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Secret_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		expect Secret
+	}{
+		{name: "string", input: `"hunter2"`, expect: Secret{Value: "hunter2"}},
+		{name: "command", input: `{"from": "command", "cmd": ["pass", "db/prod"]}`, expect: Secret{From: "command", Cmd: []string{"pass", "db/prod"}}},
+		{name: "secret service", input: `{"from": "secret_service", "attributes": {"db": "prod"}}`, expect: Secret{From: "secret_service", Attributes: map[string]string{"db": "prod"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual Secret
+			if err := json.Unmarshal([]byte(tt.input), &actual); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expect, actual); diff != "" {
+				t.Error(diff)
+			}
+
+			buf, err := json.Marshal(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var roundTrip Secret
+			if err := json.Unmarshal(buf, &roundTrip); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expect, roundTrip); diff != "" {
+				t.Error("round trip:", diff)
+			}
+		})
+	}
+}
+
+func Test_Secret_Resolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbman-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string, perm os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// a stand-in for libsecret's secret-tool, which talks to the Secret Service over D-Bus
+	writeFile("secret-tool", "#!/bin/sh\n[ \"$*\" = \"lookup db prod user admin\" ] && echo from-keyring\n", 0700)
+	defer setenv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))()
+	defer setenv(t, "DBMAN_TEST_SECRET", "from-env")()
+	defer setenv(t, "PGPASSFILE", writeFile("pgpass", "# comment\nother:*:*:*:nope\ndb\\:1:5432:*:admin:from-pgpass\n", 0600))()
+
+	registerTestSecretProvider(t, "test-provider", SecretProvider{
+		Resolve: func(s *Secret, conn *Connection) (string, error) {
+			return s.Name + "@" + conn.Host, nil
+		},
+	})
+
+	conn := &Connection{Host: "db:1", Port: 5432, Database: "prod", Username: "admin"}
+
+	tests := []struct {
+		name      string
+		secret    Secret
+		expect    string
+		expectErr bool
+	}{
+		{name: "value", secret: Secret{Value: "hunter2"}, expect: "hunter2"},
+		{name: "empty", secret: Secret{}, expect: ""},
+		{name: "env", secret: Secret{From: "env", Name: "DBMAN_TEST_SECRET"}, expect: "from-env"},
+		{name: "env unset", secret: Secret{From: "env", Name: "DBMAN_TEST_UNSET"}, expectErr: true},
+		{name: "file", secret: Secret{From: "file", Path: writeFile("password", "from-file\n", 0600)}, expect: "from-file"},
+		{name: "command", secret: Secret{From: "command", Cmd: []string{"echo", "from-command"}}, expect: "from-command"},
+		{name: "command fails", secret: Secret{From: "command", Cmd: []string{"false"}}, expectErr: true},
+		{name: "pgpass", secret: Secret{From: "pgpass"}, expect: "from-pgpass"},
+		{name: "secret service", secret: Secret{From: "secret_service", Attributes: map[string]string{"user": "admin", "db": "prod"}}, expect: "from-keyring"},
+		{name: "secret service no match", secret: Secret{From: "secret_service", Attributes: map[string]string{"db": "dev"}}, expectErr: true},
+		{name: "registered provider", secret: Secret{From: "test-provider", Name: "fake"}, expect: "fake@db:1"},
+		{name: "unknown provider", secret: Secret{From: "nope"}, expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.secret.Resolve(conn)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, but got: %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expect {
+				t.Errorf("expected %q, but got %q", tt.expect, actual)
+			}
+		})
+	}
+
+	t.Run("pgpass readable by others", func(t *testing.T) {
+		defer setenv(t, "PGPASSFILE", writeFile("pgpass-open", "*:*:*:*:from-pgpass\n", 0644))()
+
+		secret := Secret{From: "pgpass"}
+		if _, err := secret.Resolve(conn); err == nil {
+			t.Error("expected a password file with group or world access to be refused")
+		}
+	})
+}
+
+func Test_Secret_validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    Secret
+		expectErr bool
+	}{
+		{name: "value", secret: Secret{Value: "hunter2"}},
+		{name: "command", secret: Secret{From: "command", Cmd: []string{"pass"}}},
+		{name: "command missing cmd", secret: Secret{From: "command"}, expectErr: true},
+		{name: "env missing name", secret: Secret{From: "env"}, expectErr: true},
+		{name: "unknown provider", secret: Secret{From: "nope"}, expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.secret.validate("conn.password")
+			if tt.expectErr != (err != nil) {
+				t.Errorf("expected error: %v, but got: %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func Test_DBMan_SwitchConnection_secret(t *testing.T) {
+	errStop := errors.New("stop")
+	var password string
+	registerTestBackend(t, "test-secret-backend", Backend{
+		Connector: func(conn *Connection) (driver.Connector, error) {
+			password = conn.Password.Value
+			return nil, errStop
+		},
+		Meta: func(db Querier) MetaQuerier { return dbMeta{db} },
+	})
+
+	calls := 0
+	registerTestSecretProvider(t, "test-lazy-provider", SecretProvider{
+		Resolve: func(*Secret, *Connection) (string, error) {
+			calls++
+			return "from-provider", nil
+		},
+	})
+
+	cfg := Config{
+		Connections: map[string]Connection{
+			"test": {Host: "db", Port: 5432, Database: "prod", Username: "admin", Driver: "test-secret-backend", Password: Secret{From: "test-lazy-provider"}},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Fatal("expected the secret not to be resolved until connecting")
+	}
+
+	db := New(&cfg)
+	prompter := func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		t.Error("expected not to be prompted for a password")
+		return nil, errors.New("unexpected prompt")
+	}
+	if err := db.SwitchConnection("test", prompter); !errors.Is(err, errStop) {
+		t.Fatal("expected the backend's connector to be used, but got:", err)
+	}
+	if password != "from-provider" {
+		t.Errorf("expected the connector to get the resolved password, but got %q", password)
+	}
+	if cfg.Connections["test"].Password.Value != "" {
+		t.Error("expected the resolved password not to be kept in the config")
+	}
+}
+
+func Test_DBMan_SwitchConnection_lookupPassword(t *testing.T) {
+	errStop := errors.New("stop")
+	var password string
+	registerTestBackend(t, "test-lookup-backend", Backend{
+		Connector: func(conn *Connection) (driver.Connector, error) {
+			password = conn.Password.Value
+			return nil, errStop
+		},
+		Meta:           func(db Querier) MetaQuerier { return dbMeta{db} },
+		DSNPassword:    postgresDSNPassword,
+		PasswordEnv:    "DBMAN_TEST_PASSWORD",
+		LookupPassword: lookupPgpass,
+	})
+
+	dir, err := ioutil.TempDir("", "dbman-pgpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pgpass := filepath.Join(dir, "pgpass")
+	if err := ioutil.WriteFile(pgpass, []byte("db.example.com:*:*:admin:from-pgpass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer setenv(t, "PGPASSFILE", pgpass)()
+
+	prompted := func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		return []string{"from-prompt"}, nil
+	}
+
+	tests := []struct {
+		name   string
+		conn   Connection
+		env    string
+		expect string
+	}{
+		{name: "config", conn: Connection{Host: "db.example.com", Username: "admin", Password: Secret{Value: "from-config"}}, expect: "from-config"},
+		{name: "dsn before env", conn: Connection{Host: "db.example.com", Username: "admin", DSN: "password=from-dsn"}, env: "from-env", expect: "from-dsn"},
+		{name: "env before password file", conn: Connection{Host: "db.example.com", Username: "admin"}, env: "from-env", expect: "from-env"},
+		{name: "password file", conn: Connection{Host: "db.example.com", Username: "admin"}, expect: "from-pgpass"},
+		{name: "prompt", conn: Connection{Host: "db.example.com", Username: "someone"}, expect: "from-prompt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setenv(t, "DBMAN_TEST_PASSWORD", tt.env)()
+
+			tt.conn.Driver = "test-lookup-backend"
+			tt.conn.Port = 5432
+			tt.conn.Database = "app"
+			db := New(&Config{Connections: map[string]Connection{"test": tt.conn}})
+
+			password = ""
+			if err := db.SwitchConnection("test", prompted); !errors.Is(err, errStop) {
+				t.Fatal("expected the backend's connector to be used, but got:", err)
+			}
+			if password != tt.expect {
+				t.Errorf("expected %q, but got %q", tt.expect, password)
+			}
+		})
+	}
+
+	t.Run("password file readable by others", func(t *testing.T) {
+		if err := os.Chmod(pgpass, 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(pgpass, 0600)
+
+		db := New(&Config{Connections: map[string]Connection{
+			"test": {Host: "db.example.com", Port: 5432, Database: "app", Username: "admin", Driver: "test-lookup-backend"},
+		}})
+		if err := db.SwitchConnection("test", prompted); err == nil || errors.Is(err, errStop) {
+			t.Error("expected the password file's error to be returned, but got:", err)
+		}
+	})
+}
+
+// registerTestSecretProvider registers provider, and unregisters it once the test
+// is done, like registerTestBackend.
+func registerTestSecretProvider(t *testing.T, name string, provider SecretProvider) {
+	RegisterSecretProvider(name, provider)
+	t.Cleanup(func() {
+		secretProvidersMu.Lock()
+		defer secretProvidersMu.Unlock()
+		delete(secretProviders, name)
+	})
+}
+
+// setenv sets key to value, and returns a func to restore it.
+func setenv(t *testing.T, key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
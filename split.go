@@ -0,0 +1,515 @@
+package dbman
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SplitStatements splits script into its individual statements, on semicolons.
+// Semicolons within single, double, or backtick quotes, dollar-quoted strings
+// ($$, $tag$), comments (--, /* */), and BEGIN ... END blocks (e.g. the body of a
+// trigger) are ignored.
+// Statements are trimmed of whitespace and their terminating semicolon, and
+// statements containing only comments are dropped.
+//
+// Standard SQL is assumed, see Dialect.SplitStatements for a specific database's.
+func SplitStatements(script string) []string {
+	return Dialect{}.SplitStatements(script)
+}
+
+// IsTerminated reports if script doesn't end partway through a statement, i.e.
+// every statement in it is terminated by a semicolon (outside of any quotes).
+//
+// Standard SQL is assumed, see Dialect.IsTerminated for a specific database's.
+func IsTerminated(script string) bool {
+	return Dialect{}.IsTerminated(script)
+}
+
+// SplitStatements is like the package's SplitStatements, but also understands the
+// dialect's comments (e.g. mysql's #), and string escapes.
+func (d Dialect) SplitStatements(script string) []string {
+	stmts, rest := d.splitScript(script)
+	if rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+// IsTerminated is like the package's IsTerminated, but also understands the
+// dialect's comments (e.g. mysql's #), and string escapes.
+func (d Dialect) IsTerminated(script string) bool {
+	_, rest := d.splitScript(script)
+	return rest == ""
+}
+
+// splitScript splits script like SplitStatements, except that an unterminated
+// last statement is returned separately, as rest.
+func (d Dialect) splitScript(script string) (stmts []string, rest string) {
+	var (
+		start   int
+		hasCode bool
+		depth   int // of BEGIN ... END blocks, and CASE ... END expressions
+	)
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			i = skipLineComment(script, i)
+
+		case c == '#' && d.HashComments:
+			i = skipLineComment(script, i)
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			i = skipBlockComment(script, i)
+
+		case c == '\'':
+			i = skipQuoted(script, i, c, d.BackslashEscapes || isEscapeString(script, i))
+			hasCode = true
+
+		case c == '"':
+			i = skipQuoted(script, i, c, d.BackslashEscapes)
+			hasCode = true
+
+		case c == '`':
+			i = skipQuoted(script, i, c, false)
+			hasCode = true
+
+		case c == '$':
+			if tag := dollarTag(script, i); tag != "" {
+				if end := strings.Index(script[i+len(tag):], tag); end >= 0 {
+					i += len(tag) + end + len(tag)
+				} else {
+					i = len(script)
+				}
+			} else {
+				i++
+			}
+			hasCode = true
+
+		case c == ';':
+			if depth > 0 {
+				i++
+				continue
+			}
+			if hasCode {
+				stmts = append(stmts, strings.TrimSpace(script[start:i]))
+			}
+			i++
+			start = i
+			hasCode = false
+
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(script) && isIdentByte(script[end]) {
+				end++
+			}
+			depth = blockDepth(depth, script[i:end], script[start:i], script[end:])
+			i = end
+			hasCode = true
+
+		default:
+			if !unicode.IsSpace(rune(c)) {
+				hasCode = true
+			}
+			i++
+		}
+	}
+
+	if hasCode {
+		rest = strings.TrimSpace(script[start:])
+	}
+	return stmts, rest
+}
+
+// blockDepth returns depth, updated for word, which is preceded by stmt (the
+// statement so far), and followed by rest.
+// BEGIN starts a block in the body of a CREATE (e.g. a trigger), rather than a
+// transaction. Within a block, CASE starts an expression, and END finishes either,
+// unless it's e.g. END IF, which finishes something that isn't counted.
+func blockDepth(depth int, word, stmt, rest string) int {
+	switch strings.ToUpper(word) {
+	case "BEGIN":
+		if leadingKeyword(stmt) == "CREATE" {
+			return depth + 1
+		}
+
+	case "CASE":
+		if depth > 0 {
+			return depth + 1
+		}
+
+	case "END":
+		if depth == 0 {
+			break
+		}
+		switch leadingKeyword(rest) {
+		case "IF", "LOOP", "WHILE", "REPEAT", "FOR":
+			return depth
+		}
+		return depth - 1
+	}
+	return depth
+}
+
+// skipLineComment returns the index after the -- comment starting at i.
+func skipLineComment(script string, i int) int {
+	if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(script)
+}
+
+// skipBlockComment returns the index after the /* */ comment starting at i.
+// Like postgres, block comments may be nested.
+func skipBlockComment(script string, i int) int {
+	depth := 0
+	for i < len(script) {
+		switch {
+		case strings.HasPrefix(script[i:], "/*"):
+			depth++
+			i += 2
+
+		case strings.HasPrefix(script[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+
+		default:
+			i++
+		}
+	}
+	return len(script)
+}
+
+// skipQuoted returns the index after the quoted string starting at i.
+// A doubled quote is an escaped quote, as is a backslash-quote if escapes is set.
+func skipQuoted(script string, i int, quote byte, escapes bool) int {
+	for i++; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+			} else {
+				return i + 1
+			}
+		}
+	}
+	return len(script)
+}
+
+// isEscapeString reports if the quote at i starts an E'...' string, which allows backslash escapes.
+func isEscapeString(script string, i int) bool {
+	return i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i == 1 || !isIdentByte(script[i-2]))
+}
+
+// dollarTag returns the dollar-quote tag (e.g. $$, or $body$) starting at i,
+// or "" if there isn't one. Positional parameters (e.g. $1) are not tags.
+func dollarTag(script string, i int) string {
+	if i > 0 && isIdentByte(script[i-1]) {
+		return ""
+	}
+
+	for j := i + 1; j < len(script); j++ {
+		c := script[j]
+		switch {
+		case c == '$':
+			return script[i : j+1]
+
+		case c == '_' || unicode.IsLetter(rune(c)) || (j > i+1 && unicode.IsDigit(rune(c))):
+			continue
+
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// leadingKeyword returns the first word of stmt, upper-cased, skipping any
+// comments and opening parentheses.
+func leadingKeyword(stmt string) string {
+	if words := leadingKeywords(stmt, 1); len(words) != 0 {
+		return words[0]
+	}
+	return ""
+}
+
+// leadingKeywords returns up to the first n words of stmt, upper-cased, skipping
+// any comments and opening parentheses. It stops at anything else that isn't a word.
+func leadingKeywords(stmt string, n int) []string {
+	var words []string
+	for i := 0; i < len(stmt) && len(words) < n; {
+		c := stmt[i]
+		switch {
+		case c == '-' && strings.HasPrefix(stmt[i:], "--"):
+			i = skipLineComment(stmt, i)
+
+		case c == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			i = skipBlockComment(stmt, i)
+
+		case c == '(' || unicode.IsSpace(rune(c)):
+			i++
+
+		default:
+			end := i
+			for end < len(stmt) && isIdentByte(stmt[end]) {
+				end++
+			}
+			if end == i {
+				return words
+			}
+			words = append(words, strings.ToUpper(stmt[i:end]))
+			i = end
+		}
+	}
+	return words
+}
+
+// commandTag describes what stmt did, similar to postgres' command tags,
+// e.g. "UPDATE 42", or "CREATE TABLE".
+func commandTag(stmt string, rowsAffected int64) string {
+	words := leadingKeywords(stmt, 6)
+	if len(words) == 0 {
+		return ""
+	}
+
+	switch words[0] {
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE":
+		if rowsAffected >= 0 {
+			return fmt.Sprintf("%s %d", words[0], rowsAffected)
+		}
+
+	case "CREATE", "ALTER", "DROP":
+		for _, word := range words[1:] {
+			switch word {
+			case "OR", "REPLACE", "UNIQUE", "TEMP", "TEMPORARY", "UNLOGGED", "GLOBAL", "LOCAL", "RECURSIVE":
+				continue
+			}
+			return words[0] + " " + word
+		}
+
+	case "START":
+		if len(words) > 1 {
+			return words[0] + " " + words[1]
+		}
+	}
+	return words[0]
+}
+
+// execKeywords are the leading keywords of statements that don't return rows,
+// unless they have a RETURNING clause.
+var execKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "REPLACE": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "RENAME": true, "COMMENT": true,
+	"GRANT": true, "REVOKE": true,
+	"BEGIN": true, "START": true, "COMMIT": true, "END": true, "ROLLBACK": true, "ABORT": true, "SAVEPOINT": true, "RELEASE": true,
+	"SET": true, "RESET": true, "USE": true, "LOCK": true,
+	"VACUUM": true, "REINDEX": true, "CLUSTER": true, "REFRESH": true, "DISCARD": true,
+}
+
+// isExec reports if stmt is known to not return any rows, so that it can be
+// executed to find out how many rows it affected.
+func isExec(stmt string) bool {
+	return execKeywords[leadingKeyword(stmt)] && !hasWord(stmt, "RETURNING")
+}
+
+// writeKeywords are the leading keywords of statements that modify data, or the
+// schema, or run code that may (e.g. a procedure).
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "REPLACE": true, "UPSERT": true, "LOAD": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "RENAME": true, "COMMENT": true,
+	"GRANT": true, "REVOKE": true,
+	"VACUUM": true, "REINDEX": true, "CLUSTER": true, "REFRESH": true,
+	"CALL": true, "DO": true,
+}
+
+// readOnlySettings are the session settings that read only connections are opened with
+// (see each backend's Connector), or their equivalents.
+var readOnlySettings = []string{"default_transaction_read_only", "transaction_read_only", "tx_read_only", "query_only"}
+
+// isWrite reports if stmt obviously modifies data, or the schema, or lets later
+// statements do so on a read only connection, e.g. by starting a read write transaction.
+// This is a best effort check, e.g. a function called by a SELECT may still write.
+func isWrite(stmt string) bool {
+	switch keyword := leadingKeyword(stmt); keyword {
+	case "WITH":
+		// data modifying common table expressions
+		return hasWord(stmt, "INSERT", "UPDATE", "DELETE", "MERGE")
+
+	case "COPY":
+		// COPY ... FROM writes, COPY ... TO reads
+		return isCopyFrom(stmt)
+
+	case "EXPLAIN":
+		// EXPLAIN ANALYZE runs the statement it explains
+		target, analyze := explained(stmt)
+		return analyze && isWrite(target)
+
+	case "BEGIN", "START":
+		// BEGIN READ WRITE, or START TRANSACTION READ WRITE
+		return hasWord(stmt, "WRITE")
+
+	case "SET", "RESET":
+		// e.g. SET default_transaction_read_only = off, or SET TRANSACTION READ WRITE
+		return hasWord(stmt, "WRITE") || hasWord(stmt, readOnlySettings...)
+
+	case "PRAGMA":
+		// PRAGMA query_only = 0, rather than reading it
+		return hasWord(stmt, readOnlySettings...) && strings.ContainsAny(stmt, "=(")
+
+	case "SELECT":
+		// SELECT set_config('default_transaction_read_only', 'off', false)
+		return hasWord(stmt, "set_config") && hasWord(stmt, readOnlySettings...)
+
+	default:
+		return writeKeywords[keyword]
+	}
+}
+
+// nextWord returns the word at, or after any spaces and comments following, i,
+// upper-cased, and the index after it. word is "" if something other than a word is next.
+func nextWord(stmt string, i int) (word string, end int) {
+	i = skipSpace(stmt, i)
+	end = i
+	for end < len(stmt) && isIdentByte(stmt[end]) {
+		end++
+	}
+	return strings.ToUpper(stmt[i:end]), end
+}
+
+// skipSpace returns the index of the first thing after i that isn't a space, or a comment.
+func skipSpace(stmt string, i int) int {
+	for i < len(stmt) {
+		switch c := stmt[i]; {
+		case c == '-' && strings.HasPrefix(stmt[i:], "--"):
+			i = skipLineComment(stmt, i)
+
+		case c == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			i = skipBlockComment(stmt, i)
+
+		case unicode.IsSpace(rune(c)):
+			i++
+
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipParens returns the index after the parenthesized list (e.g. of columns, or
+// options) starting at i. Quoted identifiers, and strings, may contain parentheses.
+func skipParens(stmt string, i int) int {
+	depth := 0
+	for i < len(stmt) {
+		switch c := stmt[i]; c {
+		case '"', '\'', '`':
+			i = skipQuoted(stmt, i, c, false)
+			continue
+
+		case '(':
+			depth++
+
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// isCopyFrom reports if stmt is COPY table [(columns)] FROM ..., rather than
+// COPY table TO ..., or COPY (query) TO ...
+func isCopyFrom(stmt string) bool {
+	_, i := nextWord(stmt, 0)
+	i = skipSpace(stmt, i)
+	if i < len(stmt) && stmt[i] == '(' {
+		// a query, which can only be copied to somewhere
+		return false
+	}
+
+	// the table, which may be qualified, and quoted
+	for i < len(stmt) {
+		if c := stmt[i]; c == '"' {
+			i = skipQuoted(stmt, i, c, false)
+		} else if c == '.' || isIdentByte(c) {
+			i++
+		} else {
+			break
+		}
+	}
+
+	if i = skipSpace(stmt, i); i < len(stmt) && stmt[i] == '(' {
+		i = skipParens(stmt, i)
+	}
+	word, _ := nextWord(stmt, i)
+	return word == "FROM"
+}
+
+// explained returns the statement that an EXPLAIN explains, after its options,
+// and whether they include ANALYZE, i.e. the statement is run, e.g.
+//
+//	EXPLAIN ANALYZE VERBOSE DELETE FROM users
+//	EXPLAIN (ANALYZE, FORMAT JSON) DELETE FROM users
+func explained(stmt string) (target string, analyze bool) {
+	_, i := nextWord(stmt, 0)
+	if j := skipSpace(stmt, i); j < len(stmt) && stmt[j] == '(' {
+		end := skipParens(stmt, j)
+		for _, option := range strings.Split(strings.Trim(stmt[j:end], "()"), ",") {
+			fields := strings.Fields(strings.ToUpper(option))
+			if len(fields) != 0 && (fields[0] == "ANALYZE" || fields[0] == "ANALYSE") {
+				analyze = len(fields) == 1 || !(fields[1] == "FALSE" || fields[1] == "OFF" || fields[1] == "0")
+			}
+		}
+		i = end
+	}
+
+	for {
+		word, end := nextWord(stmt, i)
+		switch word {
+		case "ANALYZE", "ANALYSE":
+			analyze = true
+
+		case "VERBOSE", "EXTENDED", "PARTITIONS", "QUERY", "PLAN":
+
+		case "FORMAT":
+			// mysql's FORMAT = TREE
+			if j := skipSpace(stmt, end); j < len(stmt) && stmt[j] == '=' {
+				_, end = nextWord(stmt, j+1)
+			}
+
+		default:
+			return stmt[i:], analyze
+		}
+		i = end
+	}
+}
+
+// hasWord reports if any of words (case-insensitively) appear in stmt.
+// Quotes and comments aren't taken into account.
+func hasWord(stmt string, words ...string) bool {
+	for _, field := range strings.FieldsFunc(stmt, func(r rune) bool { return r > 0x7f || !isIdentByte(byte(r)) }) {
+		for _, word := range words {
+			if strings.EqualFold(field, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package dbman
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_SplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		in      string
+		want    []string
+	}{
+		{name: "single", in: "SELECT 1", want: []string{"SELECT 1"}},
+		{name: "terminated", in: "SELECT 1;", want: []string{"SELECT 1"}},
+		{name: "multiple", in: "SELECT 1;\nSELECT 2;\n\n", want: []string{"SELECT 1", "SELECT 2"}},
+		{name: "empty statements", in: ";; SELECT 1 ;;", want: []string{"SELECT 1"}},
+		{name: "quoted", in: `SELECT 'a;b', "c;d", ` + "`e;f`" + `; SELECT 2`, want: []string{`SELECT 'a;b', "c;d", ` + "`e;f`", "SELECT 2"}},
+		{name: "escaped quote", in: `SELECT 'it''s;'; SELECT E'\';'`, want: []string{`SELECT 'it''s;'`, `SELECT E'\';'`}},
+		{name: "backslash without E", in: `SELECT 'C:\'; SELECT 2`, want: []string{`SELECT 'C:\'`, "SELECT 2"}},
+		{
+			name: "dollar quoted",
+			in:   "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $$ $body$ LANGUAGE sql; SELECT $$;$$",
+			want: []string{"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $$ $body$ LANGUAGE sql", "SELECT $$;$$"},
+		},
+		{name: "positional parameter", in: "SELECT $1; SELECT $2", want: []string{"SELECT $1", "SELECT $2"}},
+		{name: "line comment", in: "SELECT 1 -- one; two\n; SELECT 2", want: []string{"SELECT 1 -- one; two", "SELECT 2"}},
+		{name: "block comment", in: "SELECT /* a; /* nested; */ b; */ 1; SELECT 2", want: []string{"SELECT /* a; /* nested; */ b; */ 1", "SELECT 2"}},
+		{name: "only comments", in: "-- nothing here;\n/* or; here */;", want: nil},
+		{name: "unterminated quote", in: "SELECT 'a; SELECT 2", want: []string{"SELECT 'a; SELECT 2"}},
+		{
+			name: "trigger",
+			in:   "CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE b SET n = CASE WHEN n > 0 THEN n END; DELETE FROM c; END; SELECT 2",
+			want: []string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE b SET n = CASE WHEN n > 0 THEN n END; DELETE FROM c; END", "SELECT 2"},
+		},
+		{
+			name: "procedure",
+			in:   "CREATE PROCEDURE p() BEGIN IF x THEN SELECT 1; END IF; BEGIN SELECT 2; END; END; SELECT 3",
+			want: []string{"CREATE PROCEDURE p() BEGIN IF x THEN SELECT 1; END IF; BEGIN SELECT 2; END; END", "SELECT 3"},
+		},
+		{name: "transaction", in: "BEGIN; SELECT begin, end FROM ranges; END;", want: []string{"BEGIN", "SELECT begin, end FROM ranges", "END"}},
+		{name: "hash isn't a comment", in: "SELECT 1 # 2; SELECT '{}'::jsonb #> '{a}'", want: []string{"SELECT 1 # 2", "SELECT '{}'::jsonb #> '{a}'"}},
+		{name: "mysql hash comment", dialect: Dialect{HashComments: true}, in: "SELECT 1 # one; two\n; SELECT 2", want: []string{"SELECT 1 # one; two", "SELECT 2"}},
+		{name: "mysql escapes", dialect: Dialect{BackslashEscapes: true}, in: `SELECT 'it\'s;', "a\";"; SELECT 2`, want: []string{`SELECT 'it\'s;', "a\";"`, "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.dialect.SplitStatements(tt.in)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected statements (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_IsTerminated(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "", want: true},
+		{in: "SELECT 1", want: false},
+		{in: "SELECT 1;", want: true},
+		{in: "SELECT 1;\nSELECT", want: false},
+		{in: "SELECT 1; -- done", want: true},
+		{in: "SELECT 'a;", want: false},
+		{in: "SELECT $$;\n", want: false},
+		{in: "SELECT $$;$$;", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsTerminated(tt.in); got != tt.want {
+			t.Errorf("IsTerminated(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func Test_isExec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "SELECT 1", want: false},
+		{in: "  -- comment\n/* c */ (SELECT 1)", want: false},
+		{in: "WITH x AS (SELECT 1) DELETE FROM y", want: false},
+		{in: "insert into users (name) values ('a')", want: true},
+		{in: "INSERT INTO users (name) VALUES ('a') RETURNING id", want: false},
+		{in: "UPDATE users SET returning_user = true", want: true},
+		{in: "create table users (id int)", want: true},
+		{in: "EXPLAIN DELETE FROM users", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isExec(tt.in); got != tt.want {
+			t.Errorf("%q: expected %v, but got %v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func Test_commandTag(t *testing.T) {
+	tests := []struct {
+		stmt         string
+		rowsAffected int64
+		want         string
+	}{
+		{stmt: "UPDATE users SET active = false", rowsAffected: 42, want: "UPDATE 42"},
+		{stmt: "insert into users (name) values ('a')", rowsAffected: 1, want: "INSERT 1"},
+		{stmt: "DELETE FROM users", rowsAffected: -1, want: "DELETE"},
+		{stmt: "CREATE TABLE users (id int)", rowsAffected: 0, want: "CREATE TABLE"},
+		{stmt: "create or replace function f() ...", rowsAffected: 0, want: "CREATE FUNCTION"},
+		{stmt: "CREATE UNIQUE INDEX users_name ON users (name)", rowsAffected: 0, want: "CREATE INDEX"},
+		{stmt: "DROP TABLE IF EXISTS users", rowsAffected: 0, want: "DROP TABLE"},
+		{stmt: "START TRANSACTION", rowsAffected: 0, want: "START TRANSACTION"},
+		{stmt: "-- comment\nVACUUM", rowsAffected: 0, want: "VACUUM"},
+	}
+
+	for _, tt := range tests {
+		if got := commandTag(tt.stmt, tt.rowsAffected); got != tt.want {
+			t.Errorf("%q: expected %q, but got %q", tt.stmt, tt.want, got)
+		}
+	}
+}
+
+func Test_isWrite(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "SELECT * FROM users", want: false},
+		{in: "delete from users", want: true},
+		{in: "/* cleanup */ DROP TABLE users", want: true},
+		{in: "WITH old AS (SELECT id FROM users) SELECT * FROM old", want: false},
+		{in: "WITH old AS (DELETE FROM users RETURNING id) SELECT * FROM old", want: true},
+		{in: "COPY users FROM STDIN", want: true},
+		{in: "COPY (SELECT * FROM users) TO STDOUT", want: false},
+		{in: `COPY t (a, "to") FROM stdin`, want: true},
+		{in: "COPY public.to FROM '/tmp/to.csv'", want: true},
+		{in: `COPY "my table" TO stdout`, want: false},
+		{in: "EXPLAIN SELECT 1", want: false},
+		{in: "EXPLAIN DELETE FROM users", want: false},
+		{in: "EXPLAIN ANALYZE DELETE FROM users", want: true},
+		{in: "explain analyse verbose update users set name = 'x'", want: true},
+		{in: "EXPLAIN (ANALYZE, FORMAT JSON) INSERT INTO users DEFAULT VALUES", want: true},
+		{in: "EXPLAIN (ANALYZE false) DELETE FROM users", want: false},
+		{in: "EXPLAIN ANALYZE SELECT * FROM users", want: false},
+		{in: "EXPLAIN FORMAT=TREE DELETE FROM users", want: false},
+		{in: "CALL archive_users()", want: true},
+		{in: "DO $$ BEGIN DELETE FROM users; END $$", want: true},
+		{in: "BEGIN", want: false},
+		{in: "BEGIN READ WRITE", want: true},
+		{in: "start transaction read write", want: true},
+		{in: "SET search_path = app", want: false},
+		{in: "SET default_transaction_read_only = off", want: true},
+		{in: "SET SESSION CHARACTERISTICS AS TRANSACTION READ WRITE", want: true},
+		{in: "SET @@session.transaction_read_only = 0", want: true},
+		{in: "PRAGMA query_only", want: false},
+		{in: "PRAGMA query_only = 0", want: true},
+		{in: "SELECT current_setting('transaction_read_only')", want: false},
+		{in: "SELECT set_config('default_transaction_read_only', 'off', false)", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := isWrite(tt.in); got != tt.want {
+			t.Errorf("%q: expected %v, but got %v", tt.in, tt.want, got)
+		}
+	}
+}
@@ -3,9 +3,14 @@ package dbman
 import (
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type (
@@ -54,7 +59,7 @@ func (f nullFloat64) String() string {
 
 func (t nullTime) String() string {
 	if t.Valid {
-		return t.Time.String()
+		return t.Time.Format(defaultTimeLayout)
 	}
 	return "NULL"
 }
@@ -76,6 +81,16 @@ func (i *nullInt16) Scan(v interface{}) error {
 		i.Valid = true
 		return nil
 
+	case []byte:
+		// text protocol (e.g. mysql)
+		n, err := strconv.ParseInt(string(rv), 10, 16)
+		if err != nil {
+			return err
+		}
+		i.Int16 = int16(n)
+		i.Valid = true
+		return nil
+
 	default:
 		return fmt.Errorf("unexpected type '%T'", v)
 	}
@@ -105,6 +120,16 @@ func (f *nullFloat32) Scan(v interface{}) error {
 		f.Valid = true
 		return nil
 
+	case []byte:
+		// text protocol (e.g. mysql)
+		n, err := strconv.ParseFloat(string(rv), 32)
+		if err != nil {
+			return err
+		}
+		f.Float32 = float32(n)
+		f.Valid = true
+		return nil
+
 	default:
 		return fmt.Errorf("unexpected type '%T'", v)
 	}
@@ -215,3 +240,651 @@ func (v uuidVal) String() string {
 
 	return sb.String()
 }
+
+// anyVal scans a value of a type that isn't otherwise known, e.g. a postgres enum.
+// Drivers generally send these as text, so valid UTF-8 bytes are kept as a string.
+// Rows unwraps it, so its value is returned as is.
+type anyVal struct {
+	V interface{}
+}
+
+func (a *anyVal) Scan(v interface{}) error {
+	switch rv := v.(type) {
+	case []byte:
+		if utf8.Valid(rv) {
+			a.V = string(rv)
+		} else {
+			a.V = append([]byte(nil), rv...)
+		}
+
+	default:
+		a.V = rv
+	}
+	return nil
+}
+
+// decimal is an exact decimal number, e.g. a postgres NUMERIC, kept as its text
+// so that no precision is lost.
+type decimal string
+
+// isNumber reports if d is a number, rather than NaN, or (-)Infinity.
+func (d decimal) isNumber() bool {
+	return d != "" && strings.IndexAny(string(d), "NnIi") < 0
+}
+
+type nullDecimal struct {
+	Decimal decimal
+	Valid   bool
+}
+
+func (d *nullDecimal) Scan(v interface{}) error {
+	d.Valid = true
+	switch rv := v.(type) {
+	case nil:
+		d.Decimal = ""
+		d.Valid = false
+
+	case []byte:
+		d.Decimal = decimal(rv)
+
+	case string:
+		d.Decimal = decimal(rv)
+
+	case int64:
+		d.Decimal = decimal(strconv.FormatInt(rv, 10))
+
+	case float64:
+		// e.g. sqlite, which stores decimals as floats anyway
+		d.Decimal = decimal(strconv.FormatFloat(rv, 'f', -1, 64))
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+	return nil
+}
+
+func (d nullDecimal) String() string {
+	if d.Valid {
+		return string(d.Decimal)
+	}
+	return "NULL"
+}
+
+// interval is a postgres interval. Months and days are kept separate from the
+// time, as their lengths vary.
+type interval struct {
+	Months int64
+	Days   int64
+	Micros int64
+}
+
+// parseInterval parses postgres' default (IntervalStyle postgres) output,
+// e.g. "1 year 2 mons -3 days 04:05:06.789".
+func parseInterval(s string) (interval, error) {
+	var ival interval
+	fields := strings.Fields(s)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Contains(field, ":") {
+			micros, err := parseClock(field)
+			if err != nil {
+				return interval{}, err
+			}
+			ival.Micros += micros
+			continue
+		}
+
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || i+1 == len(fields) {
+			return interval{}, fmt.Errorf("invalid interval '%s'", s)
+		}
+		i++
+
+		switch strings.TrimSuffix(fields[i], "s") {
+		case "year":
+			ival.Months += n * 12
+		case "mon":
+			ival.Months += n
+		case "day":
+			ival.Days += n
+		default:
+			return interval{}, fmt.Errorf("invalid interval '%s'", s)
+		}
+	}
+	return ival, nil
+}
+
+// parseClock parses a [-]HH:MM:SS[.ffffff] time of an interval, into microseconds.
+func parseClock(s string) (int64, error) {
+	neg := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimLeft(s, "+-"), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid interval time '%s'", s)
+	}
+
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	mins, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	secs, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	micros := (hours*3600+mins*60)*1e6 + int64(math.Round(secs*1e6))
+	if neg {
+		micros = -micros
+	}
+	return micros, nil
+}
+
+// String formats i like postgres does, e.g. "1 year 2 mons -3 days 04:05:06.789".
+func (i interval) String() string {
+	var parts []string
+	unit := func(n int64, name string) {
+		if n == 0 {
+			return
+		}
+		if n != 1 {
+			name += "s"
+		}
+		parts = append(parts, strconv.FormatInt(n, 10)+" "+name)
+	}
+	unit(i.Months/12, "year")
+	unit(i.Months%12, "mon")
+	unit(i.Days, "day")
+
+	if i.Micros != 0 || len(parts) == 0 {
+		micros, sign := i.Micros, ""
+		if micros < 0 {
+			micros, sign = -micros, "-"
+		}
+
+		clock := fmt.Sprintf("%s%02d:%02d:%02d", sign, micros/3600e6, micros/60e6%60, micros/1e6%60)
+		if frac := micros % 1e6; frac != 0 {
+			clock += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+		}
+		parts = append(parts, clock)
+	}
+	return strings.Join(parts, " ")
+}
+
+type nullInterval struct {
+	Interval interval
+	Valid    bool
+	raw      string // if the interval couldn't be parsed, e.g. another IntervalStyle
+}
+
+func (i *nullInterval) Scan(v interface{}) error {
+	var text string
+	switch rv := v.(type) {
+	case nil:
+		*i = nullInterval{}
+		return nil
+
+	case []byte:
+		text = string(rv)
+
+	case string:
+		text = rv
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+
+	ival, err := parseInterval(text)
+	if err != nil {
+		*i = nullInterval{Valid: true, raw: text}
+	} else {
+		*i = nullInterval{Interval: ival, Valid: true}
+	}
+	return nil
+}
+
+func (i nullInterval) String() string {
+	switch {
+	case !i.Valid:
+		return "NULL"
+	case i.raw != "":
+		return i.raw
+	default:
+		return i.Interval.String()
+	}
+}
+
+// nullArray is a postgres array, e.g. {1,2,NULL}. Elements are converted by elemType,
+// the database type name of the array's elements, and nested arrays are []interface{}.
+type nullArray struct {
+	Elems    []interface{}
+	Valid    bool
+	elemType string
+}
+
+func (a *nullArray) Scan(v interface{}) error {
+	var text string
+	switch rv := v.(type) {
+	case nil:
+		a.Elems = nil
+		a.Valid = false
+		return nil
+
+	case []byte:
+		text = string(rv)
+
+	case string:
+		text = rv
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+
+	// skip any dimensions, e.g. [0:1]={1,2}
+	if strings.HasPrefix(text, "[") {
+		if eq := strings.IndexByte(text, '='); eq >= 0 {
+			text = text[eq+1:]
+		}
+	}
+
+	elems, rest, err := parseArray(text, a.elemType)
+	if err != nil {
+		return err
+	}
+	if rest != "" {
+		return fmt.Errorf("unexpected '%s' after array", rest)
+	}
+	a.Elems = elems
+	a.Valid = true
+	return nil
+}
+
+func (a nullArray) String() string {
+	if a.Valid {
+		return arrayText(a.Elems)
+	}
+	return "NULL"
+}
+
+// parseArray parses the array literal at the start of s, returning what follows it.
+func parseArray(s, elemType string) (elems []interface{}, rest string, err error) {
+	if !strings.HasPrefix(s, "{") {
+		return nil, "", fmt.Errorf("invalid array '%s'", s)
+	}
+	s = s[1:]
+
+	elems = []interface{}{}
+	for len(s) > 0 {
+		switch s[0] {
+		case '}':
+			return elems, s[1:], nil
+
+		case ',':
+			s = s[1:]
+
+		case '{':
+			var nested []interface{}
+			if nested, s, err = parseArray(s, elemType); err != nil {
+				return nil, "", err
+			}
+			elems = append(elems, nested)
+
+		case '"':
+			var sb strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, "", errors.New("unterminated array element")
+			}
+			elems = append(elems, arrayElem(sb.String(), elemType))
+			s = s[i+1:]
+
+		default:
+			end := strings.IndexAny(s, ",}")
+			if end < 0 {
+				return nil, "", errors.New("unterminated array")
+			}
+			if elem := strings.TrimSpace(s[:end]); strings.EqualFold(elem, "NULL") {
+				elems = append(elems, nil)
+			} else {
+				elems = append(elems, arrayElem(elem, elemType))
+			}
+			s = s[end:]
+		}
+	}
+	return nil, "", errors.New("unterminated array")
+}
+
+// arrayElem converts an array element's text, by its database type name.
+func arrayElem(text, elemType string) interface{} {
+	switch elemType {
+	case "INT2", "INT4", "INT8", "OID":
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+
+	case "FLOAT4", "FLOAT8":
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+
+	case "NUMERIC":
+		return decimal(text)
+
+	case "BOOL":
+		return text == "t" || text == "true"
+	}
+	return text
+}
+
+// arrayText formats elems as a postgres array literal, e.g. {1,NULL,"a b"}.
+func arrayText(elems []interface{}) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, elem := range elems {
+		if i != 0 {
+			sb.WriteByte(',')
+		}
+
+		switch elem := plainValue(elem).(type) {
+		case nil:
+			sb.WriteString("NULL")
+
+		case []interface{}:
+			sb.WriteString(arrayText(elem))
+
+		default:
+			text, _ := textValue(elem)
+			sb.WriteString(quoteArrayElem(text))
+		}
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// quoteArrayElem double-quotes s, escaping quotes and backslashes, if it's empty,
+// "NULL", or contains whitespace or any of the array syntax.
+func quoteArrayElem(s string) string {
+	if s != "" && !strings.ContainsAny(s, `{},"\ `+"\t\n\r\v\f") && !strings.EqualFold(s, "NULL") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// pgRange is a postgres range, e.g. [1,5). An empty bound is unbounded.
+type pgRange struct {
+	Lower, Upper       string
+	LowerInc, UpperInc bool
+	Empty              bool
+}
+
+func parseRange(s string) (pgRange, error) {
+	if s == "empty" {
+		return pgRange{Empty: true}, nil
+	}
+	if len(s) < 3 || !strings.ContainsAny(s[:1], "[(") || !strings.ContainsAny(s[len(s)-1:], "])") {
+		return pgRange{}, fmt.Errorf("invalid range '%s'", s)
+	}
+
+	r := pgRange{LowerInc: s[0] == '[', UpperInc: s[len(s)-1] == ']'}
+
+	bounds := s[1 : len(s)-1]
+	var (
+		comma  = -1
+		quoted bool
+	)
+	for i := 0; i < len(bounds) && comma < 0; i++ {
+		switch c := bounds[i]; {
+		case c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			comma = i
+		}
+	}
+	if comma < 0 {
+		return pgRange{}, fmt.Errorf("invalid range '%s'", s)
+	}
+
+	r.Lower = unquoteBound(bounds[:comma])
+	r.Upper = unquoteBound(bounds[comma+1:])
+	return r, nil
+}
+
+// unquoteBound removes the quotes from a range bound, e.g. "2021-01-01 00:00:00".
+func unquoteBound(s string) string {
+	if !strings.HasPrefix(s, `"`) {
+		return s
+	}
+
+	var sb strings.Builder
+	s = strings.TrimSuffix(s[1:], `"`)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+		case s[i] == '"' && i+1 < len(s) && s[i+1] == '"':
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func (r pgRange) String() string {
+	if r.Empty {
+		return "empty"
+	}
+
+	lower, upper := "(", ")"
+	if r.LowerInc {
+		lower = "["
+	}
+	if r.UpperInc {
+		upper = "]"
+	}
+
+	return lower + quoteBound(r.Lower) + "," + quoteBound(r.Upper) + upper
+}
+
+// quoteBound quotes a range bound if needed, like postgres: quotes and backslashes are doubled.
+func quoteBound(s string) string {
+	if !strings.ContainsAny(s, `()[],"\ `+"\t\n\r\v\f") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`"`, `""`, `\`, `\\`).Replace(s) + `"`
+}
+
+type nullRange struct {
+	Range pgRange
+	Valid bool
+}
+
+func (r *nullRange) Scan(v interface{}) error {
+	var text string
+	switch rv := v.(type) {
+	case nil:
+		*r = nullRange{}
+		return nil
+
+	case []byte:
+		text = string(rv)
+
+	case string:
+		text = rv
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+
+	rng, err := parseRange(text)
+	if err != nil {
+		return err
+	}
+	*r = nullRange{Range: rng, Valid: true}
+	return nil
+}
+
+func (r nullRange) String() string {
+	if r.Valid {
+		return r.Range.String()
+	}
+	return "NULL"
+}
+
+// nullInet is a postgres inet or cidr, e.g. 10.0.0.1, or 10.0.0.0/8.
+type nullInet struct {
+	IP    net.IP
+	Bits  int // -1 if no netmask was given
+	Valid bool
+}
+
+func (n *nullInet) Scan(v interface{}) error {
+	var text string
+	switch rv := v.(type) {
+	case nil:
+		*n = nullInet{}
+		return nil
+
+	case []byte:
+		text = string(rv)
+
+	case string:
+		text = rv
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+
+	bits := -1
+	if slash := strings.IndexByte(text, '/'); slash >= 0 {
+		var err error
+		if bits, err = strconv.Atoi(text[slash+1:]); err != nil {
+			return fmt.Errorf("invalid netmask in '%s'", text)
+		}
+		text = text[:slash]
+	}
+
+	ip := net.ParseIP(text)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address '%s'", text)
+	}
+	*n = nullInet{IP: ip, Bits: bits, Valid: true}
+	return nil
+}
+
+func (n nullInet) String() string {
+	if !n.Valid {
+		return "NULL"
+	}
+	if n.Bits < 0 {
+		return n.IP.String()
+	}
+	return n.IP.String() + "/" + strconv.Itoa(n.Bits)
+}
+
+// nullJSON is a json or jsonb value.
+type nullJSON struct {
+	JSON  []byte
+	Valid bool
+}
+
+func (j *nullJSON) Scan(v interface{}) error {
+	switch rv := v.(type) {
+	case nil:
+		*j = nullJSON{}
+
+	case []byte:
+		*j = nullJSON{JSON: append([]byte(nil), rv...), Valid: true}
+
+	case string:
+		*j = nullJSON{JSON: []byte(rv), Valid: true}
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+	return nil
+}
+
+// String formats the JSON on a single line, with a space after each : and , like jsonb.
+// Invalid JSON is returned as is.
+func (j nullJSON) String() string {
+	if !j.Valid {
+		return "NULL"
+	}
+	if !json.Valid(j.JSON) {
+		return string(j.JSON)
+	}
+
+	var (
+		sb       strings.Builder
+		inString bool
+	)
+	for i := 0; i < len(j.JSON); i++ {
+		c := j.JSON[i]
+		switch {
+		case inString:
+			sb.WriteByte(c)
+			if c == '\\' && i+1 < len(j.JSON) {
+				i++
+				sb.WriteByte(j.JSON[i])
+			} else if c == '"' {
+				inString = false
+			}
+
+		case c == '"':
+			inString = true
+			sb.WriteByte(c)
+
+		case c == ':' || c == ',':
+			sb.WriteByte(c)
+			sb.WriteByte(' ')
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			// dropped, to be consistent regardless of the source's formatting
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// nullBytes is binary data, e.g. a postgres bytea, or a BLOB.
+type nullBytes struct {
+	Bytes []byte
+	Valid bool
+}
+
+func (b *nullBytes) Scan(v interface{}) error {
+	switch rv := v.(type) {
+	case nil:
+		*b = nullBytes{}
+
+	case []byte:
+		*b = nullBytes{Bytes: append([]byte(nil), rv...), Valid: true}
+
+	case string:
+		*b = nullBytes{Bytes: []byte(rv), Valid: true}
+
+	default:
+		return fmt.Errorf("unexpected type '%T'", v)
+	}
+	return nil
+}
+
+// String formats the bytes in hex, like postgres' bytea_output = hex.
+func (b nullBytes) String() string {
+	if b.Valid {
+		return `\x` + hex.EncodeToString(b.Bytes)
+	}
+	return "NULL"
+}
@@ -0,0 +1,239 @@
+package dbman
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	RegisterBackend("sqlite", Backend{
+		Connector:  sqliteConnector,
+		Meta:       func(db Querier) MetaQuerier { return sqliteMeta{db} },
+		Validate:   sqliteValidate,
+		Configure:  sqliteConfigure,
+		Dialect:    Dialect{NumericBools: true},
+		NoPassword: true,
+	})
+}
+
+func sqliteValidate(prefix string, conn *Connection) error {
+	var errs errorList
+
+	// a local file, so only need to know where it is
+	if conn.Database == "" {
+		errs = append(errs, errors.New(prefix+".database: required (path to the database file, or :memory:)"))
+	}
+	if conn.Tunnel != "" {
+		errs = append(errs, errors.New(prefix+".tunnel: not supported by sqlite"))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func sqliteConfigure(db *sql.DB, conn *Connection) {
+	if sqliteIsMemory(conn.Database) {
+		// an in-memory database is gone once its last connection closes
+		db.SetConnMaxIdleTime(0)
+	}
+}
+
+// sqliteIsMemory reports if path refers to an in-memory database.
+func sqliteIsMemory(path string) bool {
+	return path == ":memory:" || strings.HasPrefix(path, "file::memory:")
+}
+
+// sqliteMemoryDBs counts the opened in-memory databases, to name them uniquely.
+var sqliteMemoryDBs int32
+
+// sqliteConnector opens the database file located at conn.Database.
+// conn.DriverOpts are passed through as query parameters, e.g. "mode": "ro".
+func sqliteConnector(conn *Connection) (driver.Connector, error) {
+	params := make(url.Values, len(conn.DriverOpts))
+
+	path := conn.Database
+	if sqliteIsMemory(path) {
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			params, _ = url.ParseQuery(path[i+1:])
+		}
+
+		// otherwise, each connection gets its own empty database
+		path = fmt.Sprintf("file:dbman-memory-%d", atomic.AddInt32(&sqliteMemoryDBs, 1))
+		params.Set("mode", "memory")
+		params.Set("cache", "shared")
+	} else {
+		var err error
+		path, err = expandHome(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not expand database path: %w", err)
+		}
+	}
+
+	for k, v := range conn.DriverOpts {
+		params.Set(k, v)
+	}
+	if isTrue(conn.ReadOnly) {
+		// set last, so that driver_opts can't override it
+		params.Set("_query_only", "1")
+	}
+
+	dsn := path
+	if len(params) != 0 {
+		dsn += "?" + params.Encode()
+	}
+
+	return DSNConnector(&sqlite3.SQLiteDriver{}, dsn), nil
+}
+
+// sqliteMeta treats attached databases (e.g. main, temp) as schemas.
+type sqliteMeta struct {
+	Querier
+}
+
+func (m sqliteMeta) ListTables(ctx context.Context) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT name FROM sqlite_master
+                          WHERE type IN ('table', 'view')
+                          AND name NOT LIKE 'sqlite_%'
+                          ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+func (m sqliteMeta) ListTablesInSchema(ctx context.Context, schema string) ([]string, error) {
+	// schema names can't be bound as parameters
+	rows, err := m.QueryContext(ctx, `SELECT name FROM `+sqliteQuoteIdent(schema)+`.sqlite_master
+                          WHERE type IN ('table', 'view')
+                          AND name NOT LIKE 'sqlite_%'
+                          ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+func (m sqliteMeta) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := m.QueryContext(ctx, `SELECT name FROM pragma_database_list ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, name)
+	}
+
+	return schemas, rows.Err()
+}
+
+func (m sqliteMeta) DescribeTable(ctx context.Context, tablename string) (*TableSchema, error) {
+	var (
+		schema string
+		table  string
+	)
+	parts := strings.Split(tablename, ".")
+	switch len(parts) {
+	case 2:
+		schema = parts[0]
+		table = parts[1]
+
+	case 1:
+		schema = "main"
+		table = parts[0]
+
+	default:
+		return nil, fmt.Errorf("invalid table name: '%s'", tablename)
+	}
+
+	rows, err := m.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk
+                          FROM pragma_table_info(?, ?)
+                          ORDER BY cid`, table, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := TableSchema{
+		Name: table,
+	}
+	for rows.Next() {
+		var col ColumnSchema
+
+		var (
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&col.Name, &col.Type, &notNull, &defaultVal, &primaryKey); err != nil {
+			return nil, err
+		}
+
+		if defaultVal.Valid {
+			col.Attrs = append(col.Attrs, "DEFAULT "+defaultVal.String)
+		}
+
+		if notNull {
+			col.Attrs = append(col.Attrs, "NOT NULL")
+		} else {
+			col.Attrs = append(col.Attrs, "NULL")
+		}
+
+		if primaryKey != 0 {
+			col.Attrs = append(col.Attrs, "PRIMARY KEY")
+		}
+
+		result.Columns = append(result.Columns, col)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// pragma_table_info returns nothing rather than an error for unknown tables
+	if len(result.Columns) == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tablename)
+	}
+	return &result, nil
+}
+
+func sqliteQuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,367 @@
+package dbman
+
+import (
+	"context"
+	"database/sql/driver"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_sqliteMeta(t *testing.T) {
+	cfg := Config{
+		Connections: map[string]Connection{
+			"fixture": {
+				Database: filepath.Join(t.TempDir(), "fixture.db"),
+				Driver:   "sqlite",
+			},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal("unexpected invalid config:", err)
+	}
+
+	ctx := context.Background()
+	db := New(&cfg)
+	defer db.Close()
+
+	if err := db.SwitchConnection("fixture", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := collectScript(ctx, db, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, active BOOLEAN DEFAULT 1);
+		CREATE TABLE events (id INTEGER PRIMARY KEY, user_id INTEGER);
+		INSERT INTO users (name) VALUES ('alice'), ('bob');
+	`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Statement, result.Err)
+		}
+	}
+	if len(results) != 3 || results[2].RowsAffected != 2 {
+		t.Fatalf("expected 3 statements, the last inserting 2 rows, but got: %+v", results)
+	}
+
+	t.Run("ListSchemas", func(t *testing.T) {
+		schemas, err := db.ListSchemas(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"main"}, schemas); diff != "" {
+			t.Errorf("unexpected schemas (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("ListTables", func(t *testing.T) {
+		tables, err := db.ListTables(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"events", "users"}, tables); diff != "" {
+			t.Errorf("unexpected tables (-want +got):\n%s", diff)
+		}
+
+		tables, err = db.ListTables(ctx, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"events", "users"}, tables); diff != "" {
+			t.Errorf("unexpected tables in main (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("DescribeTable", func(t *testing.T) {
+		expect := &TableSchema{
+			Name: "users",
+			Columns: []ColumnSchema{
+				{Name: "id", Type: "INTEGER", Attrs: []string{"NULL", "PRIMARY KEY"}},
+				{Name: "name", Type: "TEXT", Attrs: []string{"NOT NULL"}},
+				{Name: "active", Type: "BOOLEAN", Attrs: []string{"DEFAULT 1", "NULL"}},
+			},
+		}
+
+		for _, name := range []string{"users", "main.users"} {
+			schema, err := db.DescribeTable(ctx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expect, schema); diff != "" {
+				t.Errorf("%s: unexpected schema (-want +got):\n%s", name, diff)
+			}
+		}
+
+		if _, err := db.DescribeTable(ctx, "nope"); err == nil {
+			t.Error("expected an error describing a table that doesn't exist")
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		result, err := db.Query(ctx, `SELECT id, name FROM users ORDER BY id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Rows) != 2 {
+			t.Fatalf("expected 2 rows, but got %d", len(result.Rows))
+		}
+		if name := result.Rows[1][1].(nullString).String(); name != "bob" {
+			t.Errorf("expected 'bob', but got '%s'", name)
+		}
+	})
+	t.Run("bind variables", func(t *testing.T) {
+		vars := func(name string) (string, error) { return "2", nil }
+		results, err := collectScript(ctx, db, `SELECT name FROM users WHERE id = :id`, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Err != nil || len(results[0].Rows) != 1 {
+			t.Fatalf("expected a single row, but got: %+v", results)
+		}
+		if name := results[0].Rows[0][0].(nullString).String(); name != "bob" {
+			t.Errorf("expected 'bob', but got '%s'", name)
+		}
+	})
+}
+
+func Test_DBMan_ListTables_pinnedConn(t *testing.T) {
+	cfg := Config{
+		Connections: map[string]Connection{
+			"single": {
+				Database:     filepath.Join(t.TempDir(), "single.db"),
+				Driver:       "sqlite",
+				MaxOpenConns: 1,
+			},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal("unexpected invalid config:", err)
+	}
+
+	db := New(&cfg)
+	defer db.Close()
+	if err := db.SwitchConnection("single", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// pins the only connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := db.ListTables(ctx, "")
+	if err != nil {
+		t.Fatal("expected the pinned connection to be used, but got:", err)
+	}
+	if diff := cmp.Diff([]string{"users"}, tables); diff != "" {
+		t.Errorf("expected the table created in the transaction (-want +got):\n%s", diff)
+	}
+}
+
+func Test_sqliteConnector(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		conn Connection
+		want string
+	}{
+		{name: "home", conn: Connection{Database: "~/data/app.db"}, want: filepath.Join(home, "data/app.db")},
+		{name: "tilde in name", conn: Connection{Database: "/tmp/backup~1.db"}, want: "/tmp/backup~1.db"},
+		{name: "dollar in name", conn: Connection{Database: "/tmp/$HOME.db"}, want: "/tmp/$HOME.db"},
+		{name: "driver opts", conn: Connection{Database: "app.db", DriverOpts: map[string]string{"mode": "ro"}}, want: "app.db?mode=ro"},
+		{name: "read only", conn: Connection{Database: "app.db", ReadOnly: boolPtr(true), DriverOpts: map[string]string{"_query_only": "0"}}, want: "app.db?_query_only=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector, err := sqliteConnector(&tt.conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dsn := connector.(dsnConnector).dsn; dsn != tt.want {
+				t.Errorf("expected %q, but got %q", tt.want, dsn)
+			}
+		})
+	}
+}
+
+func Test_DBMan_QueryRows_cappedKeepsConn(t *testing.T) {
+	backend, _ := lookupBackend("sqlite")
+	backend.Connector = func(conn *Connection) (driver.Connector, error) {
+		connector, err := sqliteConnector(conn)
+		return cancelBreaksConnector{connector}, err
+	}
+	registerTestBackend(t, "test-cancel-breaks", backend)
+
+	cfg := Config{
+		Connections: map[string]Connection{
+			"test": {
+				Database:     filepath.Join(t.TempDir(), "test.db"),
+				Driver:       "test-cancel-breaks",
+				MaxOpenConns: 1,
+			},
+		},
+		MaxRows: 2,
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal("unexpected invalid config:", err)
+	}
+
+	db := New(&cfg)
+	defer db.Close()
+	if err := db.SwitchConnection("test", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collectScript(ctx, db, "CREATE TABLE users (id INTEGER PRIMARY KEY); INSERT INTO users (id) VALUES (1), (2), (3)", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.Query(ctx, "SELECT id FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.More {
+		t.Fatal("expected the query to be capped")
+	}
+
+	// abandoned after the first row, e.g. by quitting the pager
+	rows, err := db.QueryRows(ctx, "SELECT id FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Next()
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = db.Query(ctx, "SELECT count(*) FROM users")
+	if err != nil {
+		t.Fatal("expected the session's connection to still be usable, but got:", err)
+	}
+	if diff := cmp.Diff([][]interface{}{{int64(3)}}, result.Rows); diff != "" {
+		t.Errorf("expected the rows inserted in the transaction (-want +got):\n%s", diff)
+	}
+	if !db.InTransaction() {
+		t.Error("expected the transaction to still be open")
+	}
+}
+
+// cancelBreaksConnector's connections are broken by a query being cancelled before
+// its rows are closed, like lib/pq's.
+type cancelBreaksConnector struct {
+	driver.Connector
+}
+
+func (c cancelBreaksConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cancelBreaksConn{Conn: conn}, nil
+}
+
+type cancelBreaksConn struct {
+	driver.Conn
+	bad bool
+}
+
+func (c *cancelBreaksConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.bad {
+		return nil, driver.ErrBadConn
+	}
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &cancelBreaksRows{Rows: rows, ctx: ctx, conn: c}, nil
+}
+
+func (c *cancelBreaksConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.bad {
+		return nil, driver.ErrBadConn
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+type cancelBreaksRows struct {
+	driver.Rows
+	ctx  context.Context
+	conn *cancelBreaksConn
+}
+
+func (r *cancelBreaksRows) Close() error {
+	if r.ctx.Err() != nil {
+		r.conn.bad = true
+	}
+	return r.Rows.Close()
+}
+
+func Test_DBMan_ListTables_inTransaction(t *testing.T) {
+	backend, _ := lookupBackend("sqlite")
+	backend.Connector = func(conn *Connection) (driver.Connector, error) {
+		connector, err := sqliteConnector(conn)
+		return cancelBreaksConnector{connector}, err
+	}
+	registerTestBackend(t, "test-cancel-breaks", backend)
+
+	cfg := Config{
+		Connections: map[string]Connection{
+			"test": {
+				Database:     filepath.Join(t.TempDir(), "test.db"),
+				Driver:       "test-cancel-breaks",
+				MaxOpenConns: 2,
+			},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal("unexpected invalid config:", err)
+	}
+
+	db := New(&cfg)
+	defer db.Close()
+	if err := db.SwitchConnection("test", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := db.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. completion's deadline passing
+	expired, cancel := context.WithCancel(ctx)
+	cancel()
+	tables, err := db.ListTables(expired, "")
+	if err != nil {
+		t.Fatal("expected the transaction's connection to be used without a deadline, but got:", err)
+	}
+	if diff := cmp.Diff([]string{"users"}, tables); diff != "" {
+		t.Errorf("expected the table created in the transaction (-want +got):\n%s", diff)
+	}
+
+	if _, err := db.Query(ctx, "SELECT id FROM users"); err != nil || !db.InTransaction() {
+		t.Errorf("expected the transaction to still be open, but got: %v", err)
+	}
+}
@@ -63,8 +63,12 @@ func NewTunnel(prompter ssh.KeyboardInteractiveChallenge, tunnel *SSHTunnel, hos
 	var auth ssh.AuthMethod
 	switch tunnel.AuthMethod {
 	case PasswordAuth:
-		if tunnel.Password != "" {
-			auth = ssh.Password(tunnel.Password)
+		password, err := tunnel.Password.Resolve(nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve password: %w", err)
+		}
+		if password != "" {
+			auth = ssh.Password(password)
 		} else {
 			auth = ssh.RetryableAuthMethod(ssh.KeyboardInteractive(prompter), promptNumRetries)
 		}
@@ -84,8 +88,12 @@ func NewTunnel(prompter ssh.KeyboardInteractiveChallenge, tunnel *SSHTunnel, hos
 					return nil, fmt.Errorf("could not parse private key: %w", err)
 				}
 
-				if tunnel.PrivateKeyPassphrase != "" {
-					signer, err = ssh.ParsePrivateKeyWithPassphrase(buf, []byte(tunnel.PrivateKeyPassphrase))
+				passphrase, err := tunnel.PrivateKeyPassphrase.Resolve(nil)
+				if err != nil {
+					return nil, fmt.Errorf("could not resolve private key passphrase: %w", err)
+				}
+				if passphrase != "" {
+					signer, err = ssh.ParsePrivateKeyWithPassphrase(buf, []byte(passphrase))
 				} else {
 					for i := 0; i < promptNumRetries; i++ {
 						var answers []string
@@ -0,0 +1,96 @@
+package dbman
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// Begin opens a transaction on the current connection. Statements run within it
+// until Commit or Rollback is called.
+// Transactions may also be managed with statements, e.g. BEGIN and COMMIT.
+func (d *DBMan) Begin(ctx context.Context) error {
+	if d.current == nil {
+		return errors.New("an active connection is required")
+	}
+	if d.current.inTx {
+		return errors.New("a transaction is already open")
+	}
+
+	_, err := d.Query(ctx, "BEGIN")
+	return err
+}
+
+func (d *DBMan) Commit(ctx context.Context) error {
+	if d.current == nil {
+		return errors.New("an active connection is required")
+	}
+	if !d.current.inTx {
+		return errors.New("no transaction is open")
+	}
+
+	_, err := d.Query(ctx, "COMMIT")
+	return err
+}
+
+func (d *DBMan) Rollback(ctx context.Context) error {
+	if d.current == nil {
+		return errors.New("an active connection is required")
+	}
+	if !d.current.inTx {
+		return errors.New("no transaction is open")
+	}
+
+	_, err := d.Query(ctx, "ROLLBACK")
+	return err
+}
+
+// InTransaction reports if the current connection has an open transaction.
+func (d *DBMan) InTransaction() bool {
+	return d.current != nil && d.current.inTx
+}
+
+// OpenTransactions returns the sorted names of connections with an open transaction.
+func (d *DBMan) OpenTransactions() []string {
+	var names []string
+	for name, s := range d.sessions {
+		if s.inTx {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// trackTransaction notes if stmt, which ran successfully, opened or closed a transaction.
+func (s *session) trackTransaction(stmt string) {
+	words := leadingKeywords(stmt, 2)
+	if len(words) == 0 {
+		return
+	}
+
+	switch words[0] {
+	case "BEGIN":
+		s.inTx = true
+
+	case "START":
+		if len(words) > 1 && words[1] == "TRANSACTION" {
+			s.inTx = true
+		}
+
+	case "COMMIT":
+		s.inTx = false
+
+	case "END", "ABORT":
+		// postgres' synonyms for COMMIT and ROLLBACK, rather than e.g. END IF
+		if len(words) < 2 || words[1] == "TRANSACTION" || words[1] == "WORK" {
+			s.inTx = false
+		}
+
+	case "ROLLBACK":
+		// ROLLBACK TO SAVEPOINT keeps the transaction open
+		if len(words) < 2 || words[1] != "TO" {
+			s.inTx = false
+		}
+	}
+}